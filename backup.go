@@ -0,0 +1,118 @@
+package nabu
+
+import (
+	"github.com/karlseguin/nabu/storage"
+	"io"
+	"io/ioutil"
+	"os"
+	"strconv"
+)
+
+// Writes a consistent copy of the database, including bulk loaded sort
+// indexes and namespaces, to a new data file at path. The copy is made
+// from a snapshot, so updates aren't blocked while it's written. Each
+// namespace is copied from its own snapshot, loading it if needed. The
+// file can be loaded with the RestoreFrom configuration, or used as a
+// DbPath
+func (d *Database) Backup(path string) error {
+	temp := path + ".tmp"
+	os.Remove(temp)
+	store := storage.New(temp)
+	store.Begin()
+	err := d.backup(store)
+	if err == nil {
+		err = store.Commit()
+	}
+	if closeErr := store.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(temp)
+		return err
+	}
+	return os.Rename(temp, path)
+}
+
+func (d *Database) backup(store storage.Storage) error {
+	snapshot := d.Snapshot()
+	defer snapshot.Close()
+	for _, id := range snapshot.ids() {
+		record, err := d.exportDocument(snapshot, id)
+		if err != nil {
+			return err
+		}
+		if record == nil {
+			continue
+		}
+		codec, _ := getCodec(record.Codec)
+		buffer := id.Serialize()
+		store.PutDocument(buffer.Bytes(), frameValue(codec, record.Type, record.Data))
+		if len(record.StringId) != 0 {
+			store.PutMapping(record.StringId, buffer.Bytes())
+		}
+		buffer.Close()
+	}
+	for _, record := range d.exportSorts(snapshot) {
+		store.PutSort(record.Sort, serializeSort(record.Ids, record.Scores, record.Ranked))
+	}
+	for _, name := range d.namespaceNames() {
+		if err := d.Namespace(name).db.backup(store.Namespace(name)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Replaces the data file with the configured backup, returning the path
+// the database should be loaded from. A marker next to the data file
+// records which backup was restored, so that restarting with the same
+// configuration keeps the writes made since
+func restoreBackup(c *Configuration) string {
+	if c.persist == false {
+		return c.restoreFrom
+	}
+	info, err := os.Stat(c.restoreFrom)
+	if err != nil {
+		panic("nabu: could not restore " + c.restoreFrom + ": " + err.Error())
+	}
+	marker := c.dbPath + ".restored"
+	restored := c.restoreFrom + "\n" + strconv.FormatInt(info.Size(), 10) + "\n" + strconv.FormatInt(info.ModTime().UnixNano(), 10)
+	if previous, err := ioutil.ReadFile(marker); err == nil && string(previous) == restored {
+		if _, err := os.Stat(c.dbPath); err == nil {
+			return c.dbPath
+		}
+	}
+	if err := copyFile(c.restoreFrom, c.dbPath); err != nil {
+		panic("nabu: could not restore " + c.restoreFrom + ": " + err.Error())
+	}
+	// a leftover journal would be applied to the restored file
+	os.Remove(c.dbPath + "-journal")
+	if err := ioutil.WriteFile(marker, []byte(restored), 0600); err != nil {
+		panic("nabu: could not record the restore of " + c.restoreFrom + ": " + err.Error())
+	}
+	return c.dbPath
+}
+
+func copyFile(from, to string) error {
+	source, err := os.Open(from)
+	if err != nil {
+		return err
+	}
+	defer source.Close()
+	temp := to + ".tmp"
+	target, err := os.Create(temp)
+	if err != nil {
+		return err
+	}
+	if _, err = io.Copy(target, source); err == nil {
+		err = target.Sync()
+	}
+	if closeErr := target.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(temp)
+		return err
+	}
+	return os.Rename(temp, to)
+}
@@ -0,0 +1,95 @@
+package nabu
+
+import (
+	"github.com/karlseguin/gspec"
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+)
+
+func TestBacksUpAndRestoresWithoutPersistence(t *testing.T) {
+	spec := gspec.New(t)
+	dir, _ := ioutil.TempDir("", "nabu")
+	defer os.RemoveAll(dir)
+	source := New(SmallConfig().StringFactory(characterFactory))
+	defer source.Close()
+	for _, name := range []string{"leto", "paul", "ghanima"} {
+		source.Update(&Character{Id: name, Power: len(name)})
+	}
+	source.LoadSortedInts("trending", []uint{3, 1}, []int{1, 2})
+	backup := path.Join(dir, "backup.db")
+	spec.Expect(source.Backup(backup)).ToBeNil()
+	source.Update(&Character{Id: "alia", Power: 4})
+
+	restored := New(Configure().QueryPoolSize(1).ResultsPoolSize(1, 1).NoPersistence().RestoreFrom(backup).StringFactory(characterFactory))
+	defer restored.Close()
+	spec.Expect(restored.StringGet("ghanima").(*Character).Power).ToEqual(7)
+	spec.Expect(restored.StringGet("alia")).ToBeNil()
+	assertIds(t, executeIds(restored.Query("trending")), 3, 1)
+	assertIds(t, executeIds(restored.Query("power")), 1, 2, 3)
+}
+
+func TestRestoresABackupOverTheDataFile(t *testing.T) {
+	spec := gspec.New(t)
+	dir, _ := ioutil.TempDir("", "nabu")
+	defer os.RemoveAll(dir)
+	config := func() *Configuration {
+		return Configure().QueryPoolSize(1).ResultsPoolSize(1, 1).DbPath(path.Join(dir, "data.db")).CodecIntFactory(productFactory)
+	}
+	db := New(config())
+	db.Update(NewProduct(1, "first"))
+	backup := path.Join(dir, "backup.db")
+	spec.Expect(db.Backup(backup)).ToBeNil()
+	db.Update(NewProduct(2, "second"))
+	db.Close()
+
+	db = New(config().RestoreFrom(backup))
+	spec.Expect(db.Get(1).(*Product).Name).ToEqual("first")
+	spec.Expect(db.Get(2)).ToBeNil()
+	db.Update(NewProduct(3, "third"))
+	db.Close()
+
+	db = New(config())
+	spec.Expect(db.Get(3).(*Product).Name).ToEqual("third")
+	spec.Expect(db.Get(2)).ToBeNil()
+	db.Close()
+
+	db = New(config().RestoreFrom(backup))
+	spec.Expect(db.Get(3).(*Product).Name).ToEqual("third")
+	db.Update(NewProduct(4, "fourth"))
+	db.Close()
+
+	db = New(config().RestoreFrom(backup))
+	spec.Expect(db.Get(3).(*Product).Name).ToEqual("third")
+	spec.Expect(db.Get(4).(*Product).Name).ToEqual("fourth")
+	spec.Expect(db.Backup(backup)).ToBeNil()
+	db.Update(NewProduct(5, "fifth"))
+	db.Close()
+
+	db = New(config().RestoreFrom(backup))
+	defer db.Close()
+	spec.Expect(db.Get(4).(*Product).Name).ToEqual("fourth")
+	spec.Expect(db.Get(5)).ToBeNil()
+}
+
+func TestBacksUpNamespaces(t *testing.T) {
+	spec := gspec.New(t)
+	dir, _ := ioutil.TempDir("", "nabu")
+	defer os.RemoveAll(dir)
+	source := New(SmallConfig().CodecIntFactory(productFactory))
+	defer source.Close()
+	source.Update(NewProduct(1, "root"))
+	source.Namespace("t42").Update(NewProduct(1, "tenant"))
+	source.Namespace("t42").LoadSortedInts("trending", []uint{1}, []int{1})
+	source.Namespace("eu").Update(NewProduct(2, "region"))
+	backup := path.Join(dir, "backup.db")
+	spec.Expect(source.Backup(backup)).ToBeNil()
+
+	restored := New(Configure().QueryPoolSize(1).ResultsPoolSize(1, 1).NoPersistence().RestoreFrom(backup).CodecIntFactory(productFactory))
+	defer restored.Close()
+	spec.Expect(restored.Get(1).(*Product).Name).ToEqual("root")
+	spec.Expect(restored.Namespace("t42").Get(1).(*Product).Name).ToEqual("tenant")
+	assertIds(t, executeIds(restored.Namespace("t42").Query("trending")), 1)
+	spec.Expect(restored.Namespace("eu").Get(2).(*Product).Name).ToEqual("region")
+}
@@ -1,13 +1,42 @@
 package nabu
 
 import (
+	"github.com/karlseguin/nabu/indexes"
 	"github.com/karlseguin/nabu/key"
 	"sync"
 )
 
+const INTERFACE_SIZE = 16
+
 // Documents are sharded across multiple buckets to increase
 // concurrency
 type Bucket struct {
 	sync.RWMutex
-	Lookup map[key.Type]Document
+	memory  int
+	version uint64
+	Lookup  map[key.Type]Document
+}
+
+// Documents can implement Sized to have their approximate size, in bytes,
+// included in memory estimates
+type Sized interface {
+	Size() int
+}
+
+// Approximate number of bytes used by a document within a bucket
+func documentMemory(doc Document) int {
+	memory := indexes.KEY_SIZE + INTERFACE_SIZE + indexes.MAP_ENTRY_OVERHEAD
+	if sized, ok := doc.(Sized); ok {
+		memory += sized.Size()
+	}
+	return memory
+}
+
+// Copies the documents, assumes the bucket is already locked
+func (b *Bucket) copyLookup() map[key.Type]Document {
+	lookup := make(map[key.Type]Document, len(b.Lookup))
+	for id, doc := range b.Lookup {
+		lookup[id] = doc
+	}
+	return lookup
 }
@@ -0,0 +1,354 @@
+// Inspects the data file written by Nabu's SQLite storage engine
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/karlseguin/nabu"
+	"github.com/karlseguin/nabu/key"
+	"github.com/karlseguin/nabu/storage"
+	"github.com/vmihailenco/msgpack/v5"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+)
+
+const usage = `usage: nabu [-db data.db] <command>
+
+commands:
+  stats                  counts documents, mappings, sorts, types and codecs
+  get <id>               prints a document by its int or string id
+  dump [-format jsonl]   prints every document, one JSON object per line
+  mappings               prints each string id and its int id
+  verify                 decodes every record and checks the mappings
+  compact                removes superseded rows and reclaims space
+`
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+func run(args []string, out, errOut io.Writer) int {
+	flags := flag.NewFlagSet("nabu", flag.ContinueOnError)
+	flags.SetOutput(errOut)
+	flags.Usage = func() { fmt.Fprint(errOut, usage) }
+	path := flags.String("db", "data.db", "path to the data file")
+	if flags.Parse(args) != nil || flags.NArg() == 0 {
+		flags.Usage()
+		return 2
+	}
+	command, args := flags.Arg(0), flags.Args()[1:]
+	// only compact writes to the file
+	store, err := storage.Open(*path, command == "compact")
+	if err != nil {
+		fmt.Fprintln(errOut, err)
+		return 1
+	}
+	defer store.Close()
+
+	switch command {
+	case "stats":
+		err = stats(store, out)
+	case "get":
+		if len(args) != 1 {
+			flags.Usage()
+			return 2
+		}
+		err = get(store, args[0], out)
+	case "dump":
+		err = dump(store, args, errOut, out)
+	case "mappings":
+		err = mappings(store, out)
+	case "verify":
+		err = verify(store, out)
+	case "compact":
+		var removed int
+		if removed, err = store.Compact(); err == nil {
+			fmt.Fprintf(out, "removed %d rows\n", removed)
+		}
+	default:
+		flags.Usage()
+		return 2
+	}
+	if err != nil {
+		fmt.Fprintln(errOut, err)
+		return 1
+	}
+	return 0
+}
+
+// A document as printed by get and dump
+type record struct {
+	Id       uint        `json:"id"`
+	StringId string      `json:"stringId,omitempty"`
+	Type     string      `json:"type,omitempty"`
+	Codec    string      `json:"codec"`
+	Value    interface{} `json:"value"`
+}
+
+func newRecord(id uint, stringId string, data []byte) (*record, error) {
+	t, codec, value, err := nabu.DeserializeValue(data)
+	if err != nil {
+		return nil, err
+	}
+	return &record{id, stringId, t, codecName(codec), render(codec, value)}, nil
+}
+
+func stats(store *storage.SQLite, out io.Writer) error {
+	rows, ids := 0, make(map[uint]struct{})
+	types, codecs := make(map[string]int), make(map[string]int)
+	store.IterateDocuments(func(rawId, data []byte) {
+		rows++
+		if len(rawId) != 0 {
+			ids[key.Deserialize(rawId)] = struct{}{}
+		}
+		t, codec, _, err := nabu.DeserializeValue(data)
+		if err != nil {
+			codecs["invalid"]++
+			return
+		}
+		types[t]++
+		codecs[codecName(codec)]++
+	})
+	lookup := loadMappings(store)
+	fmt.Fprintf(out, "documents: %d (%d rows)\n", len(ids), rows)
+	fmt.Fprintf(out, "mappings: %d\n", len(lookup))
+	printCounts(out, "types", types)
+	printCounts(out, "codecs", codecs)
+	fmt.Fprintln(out, "sorts:")
+	store.IterateSorts(func(name string, value []byte) {
+		s := new(nabu.SerializedSort)
+		if err := json.Unmarshal(value, s); err != nil {
+			fmt.Fprintf(out, "  %s: invalid\n", name)
+		} else if s.Ranged {
+			fmt.Fprintf(out, "  %s: %d ids (ranked)\n", name, len(s.Ids))
+		} else {
+			fmt.Fprintf(out, "  %s: %d ids\n", name, len(s.Ids))
+		}
+	})
+	return nil
+}
+
+func get(store *storage.SQLite, id string, out io.Writer) error {
+	lookup := loadMappings(store)
+	var target uint
+	stringId, found := "", false
+	for intId, s := range lookup {
+		if s == id {
+			target, stringId, found = intId, s, true
+			break
+		}
+	}
+	if found == false {
+		n, err := strconv.ParseUint(id, 10, 64)
+		if err != nil {
+			return errors.New("document " + id + " not found")
+		}
+		target, stringId = uint(n), lookup[uint(n)]
+	}
+	// the last row written for an id is the current one
+	var data []byte
+	store.IterateDocuments(func(rawId, value []byte) {
+		if len(rawId) != 0 && key.Deserialize(rawId) == target {
+			data = value
+		}
+	})
+	if data == nil {
+		return errors.New("document " + id + " not found")
+	}
+	r, err := newRecord(target, stringId, data)
+	if err != nil {
+		return err
+	}
+	encoded, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(out, string(encoded))
+	return nil
+}
+
+func dump(store *storage.SQLite, args []string, errOut, out io.Writer) error {
+	flags := flag.NewFlagSet("dump", flag.ContinueOnError)
+	flags.SetOutput(errOut)
+	format := flags.String("format", "jsonl", "output format")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *format != "jsonl" {
+		return errors.New("unsupported format " + strconv.Quote(*format))
+	}
+	lookup := loadMappings(store)
+	encoder := json.NewEncoder(out)
+	var err error
+	store.IterateDocuments(func(rawId, data []byte) {
+		if err != nil || len(rawId) == 0 {
+			return
+		}
+		id := key.Deserialize(rawId)
+		r, e := newRecord(id, lookup[id], data)
+		if e != nil {
+			err = fmt.Errorf("document %d: %v", id, e)
+			return
+		}
+		err = encoder.Encode(r)
+	})
+	return err
+}
+
+func mappings(store *storage.SQLite, out io.Writer) error {
+	lookup := loadMappings(store)
+	ids := make([]int, 0, len(lookup))
+	for id := range lookup {
+		ids = append(ids, int(id))
+	}
+	sort.Ints(ids)
+	for _, id := range ids {
+		fmt.Fprintf(out, "%s\t%d\n", lookup[uint(id)], id)
+	}
+	return nil
+}
+
+func verify(store *storage.SQLite, out io.Writer) error {
+	problems := 0
+	report := func(format string, args ...interface{}) {
+		problems++
+		fmt.Fprintf(out, format+"\n", args...)
+	}
+
+	rows := make(map[uint]int)
+	store.IterateDocuments(func(rawId, data []byte) {
+		if len(rawId) == 0 {
+			report("document with an empty id")
+			return
+		}
+		id := key.Deserialize(rawId)
+		if rows[id]++; rows[id] == 2 {
+			report("document %d: superseded rows (run compact)", id)
+		}
+		t, codec, value, err := nabu.DeserializeValue(data)
+		if err != nil {
+			report("document %d: %v", id, err)
+			return
+		}
+		if err := check(codec, value); err != nil {
+			report("document %d (%s): %v", id, t, err)
+		}
+	})
+
+	mapped := make(map[uint]string)
+	store.IterateMappings(func(stringId string, value []byte) {
+		if len(value) == 0 {
+			report("mapping %q: empty id", stringId)
+			return
+		}
+		id := key.Deserialize(value)
+		if other, exists := mapped[id]; exists && other != stringId {
+			report("mappings %q and %q: both map to %d", other, stringId, id)
+		}
+		mapped[id] = stringId
+		if _, exists := rows[id]; exists == false {
+			report("mapping %q: document %d does not exist", stringId, id)
+		}
+	})
+	if len(mapped) > 0 {
+		for id := range rows {
+			if _, exists := mapped[id]; exists == false {
+				report("document %d: no string id mapping", id)
+			}
+		}
+	}
+
+	sorts := 0
+	store.IterateSorts(func(name string, value []byte) {
+		sorts++
+		s := new(nabu.SerializedSort)
+		if err := json.Unmarshal(value, s); err != nil {
+			report("sort %q: %v", name, err)
+		} else if s.Ranged && len(s.Scores) != len(s.Ids) {
+			report("sort %q: %d ids but %d scores", name, len(s.Ids), len(s.Scores))
+		}
+	})
+
+	if problems > 0 {
+		return fmt.Errorf("%d problems found", problems)
+	}
+	fmt.Fprintf(out, "ok: %d documents, %d mappings, %d sorts\n", len(rows), len(mapped), sorts)
+	return nil
+}
+
+// Decodes the value without knowing the document's type. Gob and
+// binary values can't be decoded without it, and are assumed valid
+func check(codec nabu.Codec, value []byte) error {
+	switch codec {
+	case nabu.JSONCodec:
+		if json.Valid(value) == false {
+			return errors.New("invalid json")
+		}
+	case nabu.MsgpackCodec:
+		var decoded interface{}
+		return msgpack.Unmarshal(value, &decoded)
+	}
+	return nil
+}
+
+// JSON and msgpack values are printed as JSON, others as base64
+func render(codec nabu.Codec, value []byte) interface{} {
+	switch codec {
+	case nabu.JSONCodec:
+		if json.Valid(value) {
+			return json.RawMessage(value)
+		}
+	case nabu.MsgpackCodec:
+		var decoded interface{}
+		if msgpack.Unmarshal(value, &decoded) == nil {
+			if _, err := json.Marshal(decoded); err == nil {
+				return decoded
+			}
+		}
+	}
+	return value
+}
+
+func codecName(codec nabu.Codec) string {
+	switch codec {
+	case nabu.JSONCodec:
+		return "json"
+	case nabu.GobCodec:
+		return "gob"
+	case nabu.MsgpackCodec:
+		return "msgpack"
+	case nabu.BinaryCodec:
+		return "binary"
+	}
+	return "codec " + strconv.Itoa(int(codec.Id()))
+}
+
+func loadMappings(store *storage.SQLite) map[uint]string {
+	lookup := make(map[uint]string)
+	store.IterateMappings(func(stringId string, value []byte) {
+		if len(value) != 0 {
+			lookup[key.Deserialize(value)] = stringId
+		}
+	})
+	return lookup
+}
+
+func printCounts(out io.Writer, title string, counts map[string]int) {
+	names := make([]string, 0, len(counts))
+	for name := range counts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	fmt.Fprintln(out, title+":")
+	for _, name := range names {
+		label := name
+		if len(label) == 0 {
+			label = "(none)"
+		}
+		fmt.Fprintf(out, "  %s: %d\n", label, counts[name])
+	}
+}
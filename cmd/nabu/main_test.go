@@ -0,0 +1,147 @@
+package main
+
+import (
+	"bytes"
+	"github.com/karlseguin/gspec"
+	"github.com/karlseguin/nabu"
+	"github.com/karlseguin/nabu/storage"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"testing"
+)
+
+func TestPrintsStats(t *testing.T) {
+	spec := gspec.New(t)
+	file, cleanup := dataFile()
+	defer cleanup()
+	out, code := execute("-db", file, "stats")
+	spec.Expect(code).ToEqual(0)
+	spec.Expect(out).ToEqual("documents: 2 (2 rows)\nmappings: 2\ntypes:\n  character: 2\ncodecs:\n  json: 1\n  msgpack: 1\nsorts:\n  power: 2 ids (ranked)\n")
+}
+
+func TestGetsADocumentByStringOrIntId(t *testing.T) {
+	spec := gspec.New(t)
+	file, cleanup := dataFile()
+	defer cleanup()
+	out, code := execute("-db", file, "get", "leto")
+	spec.Expect(code).ToEqual(0)
+	spec.Expect(out).ToEqual("{\n  \"id\": 1,\n  \"stringId\": \"leto\",\n  \"type\": \"character\",\n  \"codec\": \"json\",\n  \"value\": {\n    \"id\": \"leto\",\n    \"name\": \"Leto\"\n  }\n}\n")
+	out, _ = execute("-db", file, "get", "2")
+	spec.Expect(strings.Contains(out, `"name": "Paul"`)).ToEqual(true)
+	_, code = execute("-db", file, "get", "ghanima")
+	spec.Expect(code).ToEqual(1)
+}
+
+func TestDumpsDocumentsAsJsonLines(t *testing.T) {
+	spec := gspec.New(t)
+	file, cleanup := dataFile()
+	defer cleanup()
+	out, code := execute("-db", file, "dump", "--format", "jsonl")
+	spec.Expect(code).ToEqual(0)
+	spec.Expect(out).ToEqual(`{"id":1,"stringId":"leto","type":"character","codec":"json","value":{"id":"leto","name":"Leto"}}` + "\n" +
+		`{"id":2,"stringId":"paul","type":"character","codec":"msgpack","value":{"id":"paul","name":"Paul"}}` + "\n")
+	_, code = execute("-db", file, "dump", "--format", "csv")
+	spec.Expect(code).ToEqual(1)
+}
+
+func TestPrintsMappings(t *testing.T) {
+	spec := gspec.New(t)
+	file, cleanup := dataFile()
+	defer cleanup()
+	out, _ := execute("-db", file, "mappings")
+	spec.Expect(out).ToEqual("leto\t1\npaul\t2\n")
+}
+
+func TestVerifiesAndCompacts(t *testing.T) {
+	spec := gspec.New(t)
+	file, cleanup := dataFile()
+	defer cleanup()
+	out, code := execute("-db", file, "verify")
+	spec.Expect(code).ToEqual(0)
+	spec.Expect(out).ToEqual("ok: 2 documents, 2 mappings, 1 sorts\n")
+
+	store := storage.New(file).(*storage.SQLite)
+	store.Exec("insert into documents (id, value) values (?, ?)", []byte{1, 9}, []byte(`{"id":"leto","name":"Leto II"}`))
+	store.Exec("insert into documents (id, value) values (?, ?)", []byte{3}, []byte{nabu.VALUE_MARKER, 200})
+	store.Close()
+	out, code = execute("-db", file, "verify")
+	spec.Expect(code).ToEqual(1)
+	spec.Expect(out).ToEqual("document 1: superseded rows (run compact)\ndocument 3: unknown codec\ndocument 3: no string id mapping\n")
+
+	out, code = execute("-db", file, "compact")
+	spec.Expect(code).ToEqual(0)
+	spec.Expect(out).ToEqual("removed 1 rows\n")
+	out, _ = execute("-db", file, "get", "leto")
+	spec.Expect(strings.Contains(out, `"name": "Leto II"`)).ToEqual(true)
+}
+
+func TestRejectsMissingFilesAndUnknownCommands(t *testing.T) {
+	spec := gspec.New(t)
+	file, cleanup := dataFile()
+	defer cleanup()
+	_, code := execute("-db", file+".missing", "stats")
+	spec.Expect(code).ToEqual(1)
+	_, err := os.Stat(file + ".missing")
+	spec.Expect(os.IsNotExist(err)).ToEqual(true)
+	_, code = execute("-db", file, "drop")
+	spec.Expect(code).ToEqual(2)
+}
+
+func TestOpensFilesReadOnly(t *testing.T) {
+	spec := gspec.New(t)
+	file, cleanup := dataFile()
+	defer cleanup()
+	other := file + ".other"
+	ioutil.WriteFile(other, nil, 0600)
+	_, code := execute("-db", other, "stats")
+	spec.Expect(code).ToEqual(1)
+	info, _ := os.Stat(other)
+	spec.Expect(info.Size()).ToEqual(int64(0))
+
+	store, _ := storage.Open(file, false)
+	defer store.Close()
+	_, err := store.Exec("delete from documents")
+	spec.Expect(err).ToNotBeNil()
+}
+
+type Character struct {
+	Id   string `json:"id" msgpack:"id"`
+	Name string `json:"name" msgpack:"name"`
+}
+
+func (c *Character) ReadMeta(m *nabu.Meta) {
+	m.StringId(c.Id)
+	m.Type("character")
+}
+
+func characterFactory(stringId string, id uint, t string, data []byte, codec nabu.Codec) nabu.Document {
+	character := new(Character)
+	if err := codec.Decode(data, character); err != nil {
+		panic(err)
+	}
+	return character
+}
+
+func dataFile() (string, func()) {
+	dir, _ := ioutil.TempDir("", "nabu")
+	file := path.Join(dir, "data.db")
+	config := func(codec nabu.Codec) *nabu.Configuration {
+		return nabu.Configure().QueryPoolSize(1).ResultsPoolSize(1, 1).DbPath(file).Codec(codec).CodecStringFactory(characterFactory)
+	}
+	db := nabu.New(config(nabu.JSONCodec))
+	db.Update(&Character{"leto", "Leto"})
+	db.Close()
+	db = nabu.New(config(nabu.MsgpackCodec))
+	db.Update(&Character{"paul", "Paul"})
+	db.LoadSortedInts("power", []uint{2, 1}, []int{10, 5})
+	db.Close()
+	return file, func() { os.RemoveAll(dir) }
+}
+
+func execute(args ...string) (string, int) {
+	out := new(bytes.Buffer)
+	code := run(args, out, ioutil.Discard)
+	return out.String(), code
+}
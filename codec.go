@@ -0,0 +1,134 @@
+package nabu
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"github.com/vmihailenco/msgpack/v5"
+	"strconv"
+	"sync"
+)
+
+var (
+	JSONCodec    = new(jsonCodec)
+	GobCodec     = new(gobCodec)
+	MsgpackCodec = new(msgpackCodec)
+	BinaryCodec  = new(binaryCodec)
+
+	ErrNotBinaryMarshaler   = errors.New("value does not implement encoding.BinaryMarshaler")
+	ErrNotBinaryUnmarshaler = errors.New("value does not implement encoding.BinaryUnmarshaler")
+
+	codecLock sync.RWMutex
+	codecs    = map[byte]Codec{
+		JSONCodec.Id():    JSONCodec,
+		GobCodec.Id():     GobCodec,
+		MsgpackCodec.Id(): MsgpackCodec,
+		BinaryCodec.Id():  BinaryCodec,
+	}
+)
+
+// Encodes documents for the storage engine. The codec's id is persisted
+// alongside each document so that documents can be decoded even after the
+// configured codec changes. Ids 0-63 are reserved for Nabu's own codecs.
+type Codec interface {
+	Id() byte
+	Encode(value interface{}) ([]byte, error)
+	Decode(data []byte, into interface{}) error
+}
+
+// Makes a custom codec available for decoding persisted documents.
+// Panics when the id is reserved or already registered, since documents
+// persisted with the other codec could no longer be decoded
+func RegisterCodec(codec Codec) {
+	id := codec.Id()
+	if id < 64 {
+		panic("nabu: codec id " + strconv.Itoa(int(id)) + " is reserved")
+	}
+	codecLock.Lock()
+	defer codecLock.Unlock()
+	if _, exists := codecs[id]; exists {
+		panic("nabu: codec id " + strconv.Itoa(int(id)) + " is already registered")
+	}
+	codecs[id] = codec
+}
+
+func getCodec(id byte) (Codec, bool) {
+	codecLock.RLock()
+	defer codecLock.RUnlock()
+	codec, exists := codecs[id]
+	return codec, exists
+}
+
+// Encodes using encoding/json
+type jsonCodec struct{}
+
+func (c *jsonCodec) Id() byte {
+	return 1
+}
+
+func (c *jsonCodec) Encode(value interface{}) ([]byte, error) {
+	return json.Marshal(value)
+}
+
+func (c *jsonCodec) Decode(data []byte, into interface{}) error {
+	return json.Unmarshal(data, into)
+}
+
+// Encodes using encoding/gob
+type gobCodec struct{}
+
+func (c *gobCodec) Id() byte {
+	return 2
+}
+
+func (c *gobCodec) Encode(value interface{}) ([]byte, error) {
+	buffer := new(bytes.Buffer)
+	if err := gob.NewEncoder(buffer).Encode(value); err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
+
+func (c *gobCodec) Decode(data []byte, into interface{}) error {
+	return gob.NewDecoder(bytes.NewReader(data)).Decode(into)
+}
+
+// Encodes using msgpack
+type msgpackCodec struct{}
+
+func (c *msgpackCodec) Id() byte {
+	return 3
+}
+
+func (c *msgpackCodec) Encode(value interface{}) ([]byte, error) {
+	return msgpack.Marshal(value)
+}
+
+func (c *msgpackCodec) Decode(data []byte, into interface{}) error {
+	return msgpack.Unmarshal(data, into)
+}
+
+// Defers to the document's own MarshalBinary and UnmarshalBinary
+type binaryCodec struct{}
+
+func (c *binaryCodec) Id() byte {
+	return 4
+}
+
+func (c *binaryCodec) Encode(value interface{}) ([]byte, error) {
+	marshaler, ok := value.(encoding.BinaryMarshaler)
+	if ok == false {
+		return nil, ErrNotBinaryMarshaler
+	}
+	return marshaler.MarshalBinary()
+}
+
+func (c *binaryCodec) Decode(data []byte, into interface{}) error {
+	unmarshaler, ok := into.(encoding.BinaryUnmarshaler)
+	if ok == false {
+		return ErrNotBinaryUnmarshaler
+	}
+	return unmarshaler.UnmarshalBinary(data)
+}
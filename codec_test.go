@@ -0,0 +1,165 @@
+package nabu
+
+import (
+	"github.com/karlseguin/gspec"
+	"io/ioutil"
+	"os"
+	"path"
+	"strconv"
+	"testing"
+)
+
+func TestCodecsRoundTripValues(t *testing.T) {
+	spec := gspec.New(t)
+	for _, codec := range []Codec{JSONCodec, GobCodec, MsgpackCodec, BinaryCodec} {
+		data, err := serializeValue(codec, "product", NewProduct(4, "a|b"))
+		spec.Expect(err).ToBeNil()
+		ty, decoder, value, err := deserializeValue(data)
+		spec.Expect(err).ToBeNil()
+		spec.Expect(ty).ToEqual("product")
+		spec.Expect(decoder.Id()).ToEqual(codec.Id())
+		product := new(Product)
+		spec.Expect(decoder.Decode(value, product)).ToBeNil()
+		spec.Expect(product.Id).ToEqual(uint(4))
+		spec.Expect(product.Name).ToEqual("a|b")
+	}
+}
+
+func TestDeserializesLegacyValues(t *testing.T) {
+	spec := gspec.New(t)
+	ty, codec, value, err := deserializeValue([]byte(`product|{"id":1}`))
+	spec.Expect(err).ToBeNil()
+	spec.Expect(ty).ToEqual("product")
+	spec.Expect(codec.Id()).ToEqual(JSONCodec.Id())
+	spec.Expect(string(value)).ToEqual(`{"id":1}`)
+
+	ty, _, value, _ = deserializeValue([]byte(`{"id":1}`))
+	spec.Expect(ty).ToEqual("")
+	spec.Expect(string(value)).ToEqual(`{"id":1}`)
+}
+
+func TestDeserializeRejectsUnknownAndCorruptValues(t *testing.T) {
+	spec := gspec.New(t)
+	_, _, _, err := deserializeValue([]byte{VALUE_MARKER, 200, 0})
+	spec.Expect(err).ToEqual(ErrUnknownCodec)
+	_, _, _, err = deserializeValue([]byte{VALUE_MARKER, JSONCodec.Id(), 10, 'a'})
+	spec.Expect(err).ToEqual(ErrCorruptValue)
+}
+
+func TestUpdateReturnsEncodingErrors(t *testing.T) {
+	spec := gspec.New(t)
+	dir, _ := ioutil.TempDir("", "nabu")
+	defer os.RemoveAll(dir)
+	db := New(Configure().QueryPoolSize(1).ResultsPoolSize(1, 1).SkipLoad().DbPath(path.Join(dir, "data.db")).Codec(BinaryCodec))
+	defer db.Close()
+	spec.Expect(db.Update(NewDoc(1, nil))).ToEqual(ErrNotBinaryMarshaler)
+	spec.Expect(db.Get(1)).ToBeNil()
+}
+
+func TestRestoresDocumentsUsingTheirCodec(t *testing.T) {
+	spec := gspec.New(t)
+	dir, _ := ioutil.TempDir("", "nabu")
+	defer os.RemoveAll(dir)
+	config := func(codec Codec) *Configuration {
+		return Configure().QueryPoolSize(1).ResultsPoolSize(1, 1).DbPath(path.Join(dir, "data.db")).Codec(codec).CodecIntFactory(productFactory)
+	}
+	db := New(config(MsgpackCodec))
+	db.Update(NewProduct(1, "first"))
+	db.Close()
+
+	db = New(config(GobCodec))
+	db.Update(NewProduct(2, "second"))
+	db.Close()
+
+	db = New(config(JSONCodec))
+	defer db.Close()
+	spec.Expect(db.Get(1).(*Product).Name).ToEqual("first")
+	spec.Expect(db.Get(2).(*Product).Name).ToEqual("second")
+}
+
+type Product struct {
+	Id   uint
+	Name string
+	Rank int
+}
+
+func NewProduct(id uint, name string) *Product {
+	return &Product{Id: id, Name: name}
+}
+
+func (p *Product) ReadMeta(meta *Meta) {
+	meta.IntId(p.Id).Type("product").SortedInt("rank", p.Rank)
+}
+
+func (p *Product) MarshalBinary() ([]byte, error) {
+	return []byte(strconv.Itoa(int(p.Id)) + "|" + p.Name), nil
+}
+
+func (p *Product) UnmarshalBinary(data []byte) error {
+	for i, b := range data {
+		if b == '|' {
+			id, err := strconv.Atoi(string(data[:i]))
+			p.Id, p.Name = uint(id), string(data[i+1:])
+			return err
+		}
+	}
+	return ErrCorruptValue
+}
+
+type customCodec struct {
+	*jsonCodec
+	id byte
+}
+
+func (c *customCodec) Id() byte {
+	return c.id
+}
+
+func TestRegistersCustomCodecs(t *testing.T) {
+	spec := gspec.New(t)
+	defer func() {
+		codecLock.Lock()
+		delete(codecs, 200)
+		codecLock.Unlock()
+	}()
+	RegisterCodec(&customCodec{id: 200})
+	codec, exists := getCodec(200)
+	spec.Expect(exists).ToEqual(true)
+	spec.Expect(codec.Id()).ToEqual(byte(200))
+	spec.Expect(panicOf(func() { RegisterCodec(&customCodec{id: 200}) })).ToEqual("nabu: codec id 200 is already registered")
+	spec.Expect(panicOf(func() { RegisterCodec(&customCodec{id: 5}) })).ToEqual("nabu: codec id 5 is reserved")
+	spec.Expect(panicOf(func() { RegisterCodec(&customCodec{id: 0}) })).ToEqual("nabu: codec id 0 is reserved")
+	codec, _ = getCodec(JSONCodec.Id())
+	spec.Expect(codec).ToEqual(JSONCodec)
+}
+
+func TestConfiguringACodecRegistersIt(t *testing.T) {
+	spec := gspec.New(t)
+	defer func() {
+		codecLock.Lock()
+		delete(codecs, 201)
+		codecLock.Unlock()
+	}()
+	codec := &customCodec{id: 201}
+	Configure().Codec(codec)
+	registered, _ := getCodec(201)
+	spec.Expect(registered).ToEqual(codec)
+	Configure().Codec(codec)
+	spec.Expect(panicOf(func() { Configure().Codec(&customCodec{id: 201}) })).ToEqual("nabu: codec id 201 belongs to another codec")
+	spec.Expect(panicOf(func() { Configure().Codec(&customCodec{id: 1}) })).ToEqual("nabu: codec id 1 belongs to another codec")
+	spec.Expect(panicOf(func() { Configure().Codec(&customCodec{id: 9}) })).ToEqual("nabu: codec id 9 is reserved")
+}
+
+func panicOf(fn func()) (message interface{}) {
+	defer func() { message = recover() }()
+	fn()
+	return nil
+}
+
+func productFactory(id uint, t string, data []byte, codec Codec) Document {
+	product := new(Product)
+	if err := codec.Decode(data, product); err != nil {
+		panic(err)
+	}
+	return product
+}
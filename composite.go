@@ -0,0 +1,231 @@
+package nabu
+
+import (
+	"fmt"
+	"github.com/karlseguin/nabu/conditions"
+	"sort"
+	"strings"
+)
+
+// Separates the values of a composite index's name
+const COMPOSITE_SEPARATOR = "\x1f"
+
+// Separates the columns in the name of a composite declared by documents
+// rather than by Configuration.Composite, such as category+brand
+const COMPOSITE_COLUMN_SEPARATOR = "+"
+
+// A set index over the combined values of multiple columns
+type composite struct {
+	name    string
+	columns []string
+}
+
+func compositeIndexName(name string, values []string) string {
+	return name + "=" + strings.Join(values, COMPOSITE_SEPARATOR)
+}
+
+// Reads the document's meta, along with the composite entries derived
+// from its sets
+func (d *Database) readMeta(doc Document, meta *Meta) {
+	doc.ReadMeta(meta)
+	meta.addComposites()
+}
+
+// Add the document to the composite index of the values. For a composite
+// declared with Configuration.Composite, the values are given in the
+// order of its columns. Otherwise the name lists the columns, joined by
+// COMPOSITE_COLUMN_SEPARATOR, and queries use the composite once an
+// update declaring it is accepted. Every document with values for those
+// columns must then declare it
+func (m *Meta) Composite(name string, values ...string) *Meta {
+	if m.database == nil {
+		return m
+	}
+	columns, declared := m.database.compositeColumns(name)
+	if len(columns) < 2 {
+		if m.err == nil {
+			m.err = &SchemaError{Index: name, Used: CompositeKind}
+		}
+		return m
+	}
+	if len(columns) != len(values) {
+		if m.err == nil {
+			m.err = fmt.Errorf("nabu: composite %q has %d columns, not %d", name, len(columns), len(values))
+		}
+		return m
+	}
+	if declared == false {
+		if schema := m.database.schema; schema != nil {
+			for _, column := range columns {
+				if schema.declares(column+"=") == false {
+					if m.err == nil {
+						m.err = &SchemaError{Index: column, Declared: schema[column], Used: CompositeKind}
+					}
+					return m
+				}
+			}
+		}
+		if m.composites == nil {
+			m.composites = make(map[string][]string)
+		}
+		m.composites[name] = columns
+	}
+	m.setStrings[compositeIndexName(name, values)] = struct{}{}
+	return m
+}
+
+// The columns of the composite, and whether it's declared by the
+// configuration
+func (d *Database) compositeColumns(name string) ([]string, bool) {
+	for _, composite := range d.composites {
+		if composite.name == name {
+			return composite.columns, true
+		}
+	}
+	return strings.Split(name, COMPOSITE_COLUMN_SEPARATOR), false
+}
+
+// Makes the composites declared by an accepted document available to
+// queries
+func (d *Database) learnComposites(meta *Meta) {
+	d.compositeLock.RLock()
+	known := d.allComposites
+	d.compositeLock.RUnlock()
+
+	learned := make([]*composite, 0, len(meta.composites))
+	for name, columns := range meta.composites {
+		if hasComposite(known, name) == false {
+			learned = append(learned, &composite{name, columns})
+		}
+	}
+	if len(learned) == 0 {
+		return
+	}
+
+	d.compositeLock.Lock()
+	defer d.compositeLock.Unlock()
+	// copied rather than appended to, as queries iterate over the
+	// slice outside of the lock
+	all := make([]*composite, len(d.allComposites), len(d.allComposites)+len(learned))
+	copy(all, d.allComposites)
+	for _, composite := range learned {
+		if hasComposite(all, composite.name) == false {
+			all = append(all, composite)
+		}
+	}
+	sort.SliceStable(all, func(i, j int) bool {
+		return len(all[i].columns) > len(all[j].columns)
+	})
+	d.allComposites = all
+}
+
+func hasComposite(composites []*composite, name string) bool {
+	for _, composite := range composites {
+		if composite.name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// The composites queries can use: those declared by the configuration
+// and by documents, with the most columns first
+func (d *Database) queryComposites() []*composite {
+	d.compositeLock.RLock()
+	defer d.compositeLock.RUnlock()
+	return d.allComposites
+}
+
+// Adds the document to the composite entry of every combination of its
+// values for the columns of each composite. Documents without a value
+// for one of the columns aren't in the composite
+func (m *Meta) addComposites() {
+	if m.database == nil || len(m.database.composites) == 0 {
+		return
+	}
+	names := make([]string, 0, len(m.database.composites))
+	for _, composite := range m.database.composites {
+		combinations := [][]string{nil}
+		for _, column := range composite.columns {
+			values := m.setValues(column)
+			next := make([][]string, 0, len(combinations)*len(values))
+			for _, combination := range combinations {
+				for _, value := range values {
+					next = append(next, append(combination[:len(combination):len(combination)], value))
+				}
+			}
+			combinations = next
+		}
+		for _, values := range combinations {
+			names = append(names, compositeIndexName(composite.name, values))
+		}
+	}
+	for _, name := range names {
+		m.setStrings[name] = struct{}{}
+	}
+}
+
+// The values given to Meta.Set for the name
+func (m *Meta) setValues(name string) []string {
+	prefix := name + "="
+	values := make([]string, 0, 1)
+	for _, sets := range []map[string]struct{}{m.setStrings, m.bigSetStrings} {
+		for indexName, _ := range sets {
+			if strings.HasPrefix(indexName, prefix) {
+				values = append(values, indexName[len(prefix):])
+			}
+		}
+	}
+	return values
+}
+
+// Whether the name is one of a composite's columns
+func (d *Database) isCompositeColumn(name string) bool {
+	for _, composite := range d.queryComposites() {
+		for _, column := range composite.columns {
+			if column == name {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Replaces the Set conditions which cover every column of a composite
+// with a single condition on the composite index. Composites with more
+// columns are preferred
+func (q *NormalQuery) useComposites() {
+	if q.conditionCount < 2 {
+		return
+	}
+	for _, composite := range q.db.queryComposites() {
+		positions := make([]int, len(composite.columns))
+		values := make([]string, len(composite.columns))
+		found := 0
+		for i, column := range composite.columns {
+			for j := 0; j < q.conditionCount; j++ {
+				if set, ok := q.conditions[j].(*conditions.Set); ok && set.Name() == column {
+					positions[i], values[i] = j, set.Value()
+					found++
+					break
+				}
+			}
+		}
+		if found != len(composite.columns) {
+			continue
+		}
+		replaced := make(map[int]bool, len(positions))
+		for _, position := range positions {
+			replaced[position] = true
+		}
+		kept := 0
+		for i := 0; i < q.conditionCount; i++ {
+			if replaced[i] == false {
+				q.conditions[kept] = q.conditions[i]
+				kept++
+			}
+		}
+		q.conditions[kept] = conditions.NewSet(composite.name, strings.Join(values, COMPOSITE_SEPARATOR))
+		q.conditionCount = kept + 1
+	}
+}
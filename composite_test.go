@@ -0,0 +1,98 @@
+package nabu
+
+import (
+	"github.com/karlseguin/gspec"
+	"testing"
+)
+
+func TestCompositesAreUsedForMatchingSetConditions(t *testing.T) {
+	spec := gspec.New(t)
+	db := CatalogDB()
+	defer db.Close()
+	q := db.Query("rank").Set("brand", "acme").Where(GT("rank", 0)).Set("category", "books").(*NormalQuery)
+	q.useComposites()
+	spec.Expect(q.conditionCount).ToEqual(1)
+	spec.Expect(q.conditions[0].IndexName()).ToEqual("catalog=books" + COMPOSITE_SEPARATOR + "acme")
+	assertIds(t, executeIds(q), 1, 4)
+
+	assertIds(t, executeIds(db.Query("rank").Set("category", "books").Set("brand", "bolt")), 2)
+	assertIds(t, executeIds(db.Query("rank").Set("category", "books")), 1, 2, 4)
+	spec.Expect(db.Query("rank").Set("brand", "acme").Set("category", "games").Count()).ToEqual(1)
+}
+
+func TestCompositesAreMaintainedByUpdatesAndRemoves(t *testing.T) {
+	db := CatalogDB()
+	defer db.Close()
+	db.Update(&CatalogDoc{4, 4, "books", "bolt"})
+	db.RemoveById(2)
+	assertIds(t, executeIds(db.Query("rank").Set("category", "books").Set("brand", "acme")), 1)
+	assertIds(t, executeIds(db.Query("rank").Set("category", "books").Set("brand", "bolt")), 4)
+}
+
+func TestCompositesCoverEveryCombinationOfValues(t *testing.T) {
+	db := CatalogDB()
+	defer db.Close()
+	db.Update(&SchemaDoc{5, func(m *Meta) {
+		m.SortedInt("rank", 5).Set("category", "books", false).Set("category", "games", false).Set("brand", "bolt", true)
+	}})
+	db.Update(&SchemaDoc{6, func(m *Meta) { m.SortedInt("rank", 6).Set("category", "books", false) }})
+	assertIds(t, executeIds(db.Query("rank").Set("category", "books").Set("brand", "bolt")), 2, 5)
+	assertIds(t, executeIds(db.Query("rank").Set("category", "games").Set("brand", "bolt")), 5)
+	assertIds(t, executeIds(db.Query("rank").Set("category", "books")), 1, 2, 4, 5, 6)
+}
+
+func TestDocumentsCanDeclareTheirOwnComposites(t *testing.T) {
+	spec := gspec.New(t)
+	db := New(SmallConfig())
+	defer db.Close()
+	for _, doc := range []*CatalogDoc{{1, 1, "books", "acme"}, {2, 2, "books", "bolt"}, {3, 3, "games", "acme"}, {4, 4, "books", "acme"}} {
+		doc := doc
+		db.Update(&SchemaDoc{doc.id, func(m *Meta) {
+			doc.ReadMeta(m)
+			m.Composite("category+brand", doc.category, doc.brand)
+		}})
+	}
+	q := db.Query("rank").Set("brand", "acme").Set("category", "books").(*NormalQuery)
+	q.useComposites()
+	spec.Expect(q.conditionCount).ToEqual(1)
+	spec.Expect(q.conditions[0].IndexName()).ToEqual("category+brand=books" + COMPOSITE_SEPARATOR + "acme")
+	assertIds(t, executeIds(q), 1, 4)
+	assertIds(t, executeIds(db.Query("rank").Set("category", "books").Set("brand", "bolt")), 2)
+
+	db.RemoveById(4)
+	assertIds(t, executeIds(db.Query("rank").Set("category", "books").Set("brand", "acme")), 1)
+}
+
+func TestRejectsInvalidDocumentComposites(t *testing.T) {
+	spec := gspec.New(t)
+	db := New(SmallConfig().Schema(Schema{"category": SetKind, "rank": SortedIntKind}))
+	defer db.Close()
+	err := db.Update(&SchemaDoc{1, func(m *Meta) { m.Composite("category+brand", "books") }})
+	spec.Expect(err.Error()).ToEqual(`nabu: composite "category+brand" has 2 columns, not 1`)
+	err = db.Update(&SchemaDoc{1, func(m *Meta) { m.Composite("category", "books") }})
+	spec.Expect(err.Error()).ToEqual(`nabu: index "category" is not declared`)
+	err = db.Update(&SchemaDoc{1, func(m *Meta) { m.Composite("category+rank", "books", "1") }})
+	spec.Expect(err.Error()).ToEqual(`nabu: index "rank" is declared as a sorted int, not a composite`)
+	spec.Expect(len(db.queryComposites())).ToEqual(0)
+}
+
+type CatalogDoc struct {
+	id       uint
+	rank     int
+	category string
+	brand    string
+}
+
+func (d *CatalogDoc) ReadMeta(m *Meta) {
+	m.IntId(d.id).SortedInt("rank", d.rank)
+	m.Set("category", d.category, false).Set("brand", d.brand, false)
+}
+
+func CatalogDB() *Database {
+	db := New(SmallConfig().Composite("catalog", "category", "brand"))
+	db.Update(&CatalogDoc{1, 1, "books", "acme"})
+	db.Update(&CatalogDoc{2, 2, "books", "bolt"})
+	db.Update(&CatalogDoc{3, 3, "games", "acme"})
+	db.Update(&CatalogDoc{4, 4, "books", "acme"})
+	return db
+}
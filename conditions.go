@@ -34,6 +34,12 @@ type MultiCondition interface {
 	IndexNames() []string
 }
 
+// A condition which matches the ids missing from its indexes
+type NegatedCondition interface {
+	Condition
+	Negated() bool
+}
+
 // An array of condition
 type Conditions []Condition
 
@@ -56,11 +62,19 @@ func (c Conditions) Len() int {
 	return len(c)
 }
 
-// Used to sort an array based on length
+// Used to sort an array based on length. Negated conditions go last
 func (c Conditions) Less(i, j int) bool {
+	if negatedI, negatedJ := isNegated(c[i]), isNegated(c[j]); negatedI != negatedJ {
+		return negatedJ
+	}
 	return c[i].CanIterate() && c[i].Len() < c[j].Len()
 }
 
+func isNegated(condition Condition) bool {
+	negated, ok := condition.(NegatedCondition)
+	return ok && negated.Negated()
+}
+
 // Used to sort an array based on length
 func (c Conditions) Swap(i, j int) {
 	c[i], c[j] = c[j], c[i]
@@ -89,3 +103,16 @@ func EQ(indexName string, value int) Condition {
 func Between(indexName string, from, to int) Condition {
 	return conditions.NewBetween(indexName, from, to)
 }
+
+// Matches documents in any of the set's values
+func In(indexName string, values ...string) Condition {
+	if len(values) == 1 {
+		return conditions.NewSet(indexName, values[0])
+	}
+	return conditions.NewUnion(indexName, values)
+}
+
+// Excludes documents in any of the set's values
+func NotIn(indexName string, values ...string) Condition {
+	return conditions.NewNotIn(indexName, values)
+}
@@ -29,6 +29,11 @@ func (c *Between) Key() string {
 	return c.key
 }
 
+// The condition as expressed in a parsed query
+func (c *Between) String() string {
+	return c.indexName + " between " + strconv.Itoa(c.from) + " and " + strconv.Itoa(c.to)
+}
+
 func (c *Between) IndexName() string {
 	return c.indexName
 }
@@ -45,6 +50,9 @@ func (c *Between) Range() (int, int) {
 func (c *Between) Len() int {
 	if c.length == -1 {
 		c.length = c.index.GetRank(c.to, false) - c.index.GetRank(c.from, true) + 1
+		if c.length < 0 {
+			c.length = 0
+		}
 	}
 	return c.length
 }
@@ -27,6 +27,11 @@ func (c *Equal) Key() string {
 	return c.key
 }
 
+// The condition as expressed in a parsed query
+func (c *Equal) String() string {
+	return c.indexName + "=" + strconv.Itoa(c.value)
+}
+
 func (c *Equal) IndexName() string {
 	return c.indexName
 }
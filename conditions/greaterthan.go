@@ -27,6 +27,11 @@ func (c *GreaterThan) Key() string {
 	return c.key
 }
 
+// The condition as expressed in a parsed query
+func (c *GreaterThan) String() string {
+	return c.indexName + ">" + strconv.Itoa(c.value)
+}
+
 func (c *GreaterThan) IndexName() string {
 	return c.indexName
 }
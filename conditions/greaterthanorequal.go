@@ -27,6 +27,11 @@ func (c *GreaterThanOrEqual) Key() string {
 	return c.key
 }
 
+// The condition as expressed in a parsed query
+func (c *GreaterThanOrEqual) String() string {
+	return c.indexName + ">=" + strconv.Itoa(c.value)
+}
+
 func (c *GreaterThanOrEqual) IndexName() string {
 	return c.indexName
 }
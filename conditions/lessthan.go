@@ -27,6 +27,11 @@ func (c *LessThan) Key() string {
 	return c.key
 }
 
+// The condition as expressed in a parsed query
+func (c *LessThan) String() string {
+	return c.indexName + "<" + strconv.Itoa(c.value)
+}
+
 func (c *LessThan) IndexName() string {
 	return c.indexName
 }
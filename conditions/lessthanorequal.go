@@ -27,6 +27,11 @@ func (c *LessThanOrEqual) Key() string {
 	return c.key
 }
 
+// The condition as expressed in a parsed query
+func (c *LessThanOrEqual) String() string {
+	return c.indexName + "<=" + strconv.Itoa(c.value)
+}
+
 func (c *LessThanOrEqual) IndexName() string {
 	return c.indexName
 }
@@ -0,0 +1,97 @@
+package conditions
+
+import (
+	"github.com/karlseguin/nabu/indexes"
+	"github.com/karlseguin/nabu/key"
+	"strings"
+)
+
+// Excludes the ids found in any of the set's values. Since the ids
+// which match aren't known, a NotIn can never be iterated and is best
+// checked after every other condition
+type NotIn struct {
+	key        string
+	text       string
+	indexCount int
+	names      []string
+	indexes    indexes.Indexes
+}
+
+func NewNotIn(indexName string, values []string) *NotIn {
+	quoted := make([]string, len(values))
+	names := make([]string, len(values))
+	for i, value := range values {
+		quoted[i] = Quote(value)
+		names[i] = indexName + "=" + value
+	}
+	return &NotIn{
+		names:   names,
+		text:    indexName + " not in (" + strings.Join(quoted, ",") + ")",
+		indexes: make(indexes.Indexes, len(values)),
+		key:     indexName + " not in (" + strings.Join(values, ",") + ")",
+	}
+}
+
+func (c *NotIn) Key() string {
+	return c.key
+}
+
+// The condition as expressed in a parsed query
+func (c *NotIn) String() string {
+	return c.text
+}
+
+func (c *NotIn) IndexName() string {
+	return ""
+}
+
+func (c *NotIn) IndexNames() []string {
+	return c.names
+}
+
+// Called once per value each time the condition is prepared
+func (c *NotIn) On(index indexes.Index) {
+	if c.indexCount == len(c.indexes) {
+		c.indexCount = 0
+	}
+	c.indexes[c.indexCount] = index
+	c.indexCount++
+}
+
+// The number of excluded ids, which says nothing of how many ids match
+func (c *NotIn) Len() int {
+	length := 0
+	for _, index := range c.indexes[:c.indexCount] {
+		length += index.Len()
+	}
+	return length
+}
+
+func (c *NotIn) Contains(id key.Type) bool {
+	for _, index := range c.indexes[:c.indexCount] {
+		if index.Contains(id) {
+			return false
+		}
+	}
+	return true
+}
+
+func (c *NotIn) CanIterate() bool {
+	return false
+}
+
+func (c *NotIn) Iterator() indexes.Iterator {
+	return nil
+}
+
+func (c *NotIn) Negated() bool {
+	return true
+}
+
+func (c *NotIn) RLock() {
+	c.indexes[:c.indexCount].RLock()
+}
+
+func (c *NotIn) RUnlock() {
+	c.indexes[:c.indexCount].RUnlock()
+}
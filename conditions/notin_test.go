@@ -0,0 +1,32 @@
+package conditions
+
+import (
+	"github.com/karlseguin/gspec"
+	"github.com/karlseguin/nabu/key"
+	"testing"
+)
+
+func TestNotInContainsAnIdMissingFromEveryIndex(t *testing.T) {
+	spec := gspec.New(t)
+	notIn := NewNotIn("x", []string{"apple", "orange"})
+	notIn.On(makeSetIndex(20, 23, 24))
+	notIn.On(makeSetIndex(25, 28))
+	spec.Expect(notIn.Contains(key.Type(22))).ToEqual(true)
+}
+
+func TestNotInDoesNotContainAnIdInAnyIndex(t *testing.T) {
+	spec := gspec.New(t)
+	notIn := NewNotIn("x", []string{"apple", "orange"})
+	notIn.On(makeSetIndex(20, 23, 24))
+	notIn.On(makeSetIndex(25, 28))
+	spec.Expect(notIn.Contains(key.Type(23))).ToEqual(false)
+	spec.Expect(notIn.Contains(key.Type(28))).ToEqual(false)
+}
+
+func TestNotInCannotIterate(t *testing.T) {
+	spec := gspec.New(t)
+	notIn := NewNotIn("x", []string{"apple"})
+	spec.Expect(notIn.CanIterate()).ToEqual(false)
+	spec.Expect(notIn.IndexNames()[0]).ToEqual("x=apple")
+	spec.Expect(notIn.String()).ToEqual("x not in (apple)")
+}
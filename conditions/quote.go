@@ -0,0 +1,32 @@
+package conditions
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Words with a meaning in a parsed query, which must be quoted to be
+// used as a value
+var keywords = map[string]bool{
+	"where": true, "and": true, "in": true, "between": true,
+	"asc": true, "desc": true, "limit": true, "offset": true, "total": true,
+}
+
+// Quotes the value unless it can be parsed as a bare word
+func Quote(value string) string {
+	if len(value) == 0 || keywords[strings.ToLower(value)] {
+		return strconv.Quote(value)
+	}
+	for _, r := range value {
+		if IsWordRune(r) == false {
+			return strconv.Quote(value)
+		}
+	}
+	return value
+}
+
+// Whether the rune can be part of an unquoted word in a parsed query
+func IsWordRune(r rune) bool {
+	return (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') ||
+		r == '_' || r == '-' || r == '.' || r == ':' || r == '/' || r == '@' || r > 127
+}
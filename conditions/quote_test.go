@@ -0,0 +1,20 @@
+package conditions
+
+import (
+	"github.com/karlseguin/gspec"
+	"testing"
+)
+
+func TestQuoteLeavesBareWordsAlone(t *testing.T) {
+	spec := gspec.New(t)
+	spec.Expect(Quote("caladan")).ToEqual("caladan")
+	spec.Expect(Quote("user:age-2")).ToEqual("user:age-2")
+}
+
+func TestQuoteQuotesKeywordsAndPunctuation(t *testing.T) {
+	spec := gspec.New(t)
+	spec.Expect(Quote("And")).ToEqual(`"And"`)
+	spec.Expect(Quote("a b")).ToEqual(`"a b"`)
+	spec.Expect(Quote(`x"y`)).ToEqual(`"x\"y"`)
+	spec.Expect(Quote("")).ToEqual(`""`)
+}
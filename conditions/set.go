@@ -3,10 +3,12 @@ package conditions
 import (
 	"github.com/karlseguin/nabu/indexes"
 	"github.com/karlseguin/nabu/key"
+	"strconv"
 )
 
 type Set struct {
 	key       string
+	name      string
 	indexName string
 	value     string
 	index     indexes.Iterable
@@ -15,6 +17,7 @@ type Set struct {
 func NewSet(indexName string, value string) *Set {
 	return &Set{
 		value:     value,
+		name:      indexName,
 		indexName: indexName + "=" + value,
 		key:       indexName + "=s=" + value,
 	}
@@ -24,6 +27,24 @@ func (c *Set) Key() string {
 	return c.key
 }
 
+// The condition as expressed in a parsed query. Integers are quoted so
+// that they aren't mistaken for an equality on a sorted index
+func (c *Set) String() string {
+	if _, err := strconv.Atoi(c.value); err == nil {
+		return c.name + "=" + strconv.Quote(c.value)
+	}
+	return c.name + "=" + Quote(c.value)
+}
+
+// The name of the set, without its value
+func (c *Set) Name() string {
+	return c.name
+}
+
+func (c *Set) Value() string {
+	return c.value
+}
+
 func (c *Set) IndexName() string {
 	return c.indexName
 }
@@ -59,3 +80,11 @@ func (c *Set) RLock() {
 func (c *Set) RUnlock() {
 	c.index.RUnlock()
 }
+
+// The id at the position within the set
+func (c *Set) At(position int) key.Type {
+	if positional, ok := c.index.(indexes.Positional); ok {
+		return positional.At(position)
+	}
+	return key.NULL
+}
@@ -9,14 +9,20 @@ import (
 
 type Union struct {
 	key        string
+	text       string
 	indexCount int
 	values     []string
 	indexes    indexes.Indexes
 }
 
 func NewUnion(indexName string, values []string) *Union {
+	quoted := make([]string, len(values))
+	for i, value := range values {
+		quoted[i] = Quote(value)
+	}
 	u := &Union{
 		values:  values,
+		text:    indexName + " in (" + strings.Join(quoted, ",") + ")",
 		indexes: make(indexes.Indexes, len(values)),
 		key:     indexName + " in (" + strings.Join(values, ",") + ")",
 	}
@@ -30,6 +36,11 @@ func (c *Union) Key() string {
 	return c.key
 }
 
+// The condition as expressed in a parsed query
+func (c *Union) String() string {
+	return c.text
+}
+
 func (c *Union) IndexName() string {
 	return ""
 }
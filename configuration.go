@@ -1,5 +1,11 @@
 package nabu
 
+import (
+	"sort"
+	"strconv"
+	"time"
+)
+
 // Configuration option for a Database. Exposes as a fluent-interface
 // which begins by calling nabu.Configure()
 type Configuration struct {
@@ -8,8 +14,8 @@ type Configuration struct {
 	skipLoad               bool
 	dbPath                 string
 	persist                bool
-	iFactory               IntFactory
-	sFactory               StringFactory
+	iFactory               CodecIntFactory
+	sFactory               CodecStringFactory
 	bucketCount            int
 	defaultLimit           int
 	queryPoolSize          int
@@ -17,6 +23,17 @@ type Configuration struct {
 	maxConditionsPerQuery  int
 	sortedResultPoolSize   int
 	unsortedResultPoolSize int
+	expiryInterval         time.Duration
+	rejectStaleVersions    bool
+	memoryLimit            int
+	rejectOverMemoryLimit  bool
+	codec                  Codec
+	lazy                   bool
+	cacheSize              int
+	iterationChunkSize     int
+	restoreFrom            string
+	schema                 Schema
+	composites             []*composite
 }
 
 // Begins the configuration process.
@@ -34,6 +51,9 @@ func Configure() *Configuration {
 		sortedResultPoolSize:   512,
 		unsortedResultPoolSize: 512,
 		persist:                true,
+		expiryInterval:         time.Minute,
+		codec:                  JSONCodec,
+		iterationChunkSize:     1000,
 	}
 }
 
@@ -105,6 +125,35 @@ func (c *Configuration) SkipLoad() *Configuration {
 	return c
 }
 
+// Declares the kind of every index. Updates whose meta uses an index
+// as a different kind, or an undeclared index, are rejected with a
+// *SchemaError. Queries against undeclared indexes return no results
+func (c *Configuration) Schema(schema Schema) *Configuration {
+	c.schema = schema
+	return c
+}
+
+// Declares a set index over the combined values of the columns, which
+// the database maintains from the values documents give Meta.Set for
+// each column. Queries with a Set condition on each column use the
+// composite instead of intersecting the sets. Documents can also
+// declare their own composites (see Meta.Composite)
+func (c *Configuration) Composite(name string, columns ...string) *Configuration {
+	c.composites = append(c.composites, &composite{name, columns})
+	sort.SliceStable(c.composites, func(i, j int) bool {
+		return len(c.composites[i].columns) > len(c.composites[j].columns)
+	})
+	return c
+}
+
+// Loads the database from a backup (see Database.Backup) on startup.
+// When persisting, the backup first replaces the file at DbPath, once:
+// later starts with the same backup load DbPath as it is
+func (c *Configuration) RestoreFrom(path string) *Configuration {
+	c.restoreFrom = path
+	return c
+}
+
 // Does not persist changes to disk
 func (c *Configuration) NoPersistence() *Configuration {
 	c.persist = false
@@ -113,12 +162,89 @@ func (c *Configuration) NoPersistence() *Configuration {
 
 // The factory used to rehydrate objects on startup (when the id is a string)
 func (c *Configuration) StringFactory(factory StringFactory) *Configuration {
-	c.sFactory = factory
+	c.sFactory = func(stringId string, id uint, t string, data []byte, codec Codec) Document {
+		return factory(stringId, id, t, data)
+	}
 	return c
 }
 
 // The factory used to rehydrate objects on startup (when the id is an int)
 func (c *Configuration) IntFactory(factory IntFactory) *Configuration {
+	c.iFactory = func(id uint, t string, data []byte, codec Codec) Document {
+		return factory(id, t, data)
+	}
+	return c
+}
+
+// Like StringFactory, but the factory is also given the codec the
+// document was written with
+func (c *Configuration) CodecStringFactory(factory CodecStringFactory) *Configuration {
+	c.sFactory = factory
+	return c
+}
+
+// Like IntFactory, but the factory is also given the codec the document
+// was written with
+func (c *Configuration) CodecIntFactory(factory CodecIntFactory) *Configuration {
 	c.iFactory = factory
 	return c
 }
+
+// The codec used to encode documents for the storage engine. Defaults to
+// JSONCodec. Documents written with a different codec can still be loaded,
+// provided that codec is built-in or registered via RegisterCodec. A
+// codec which isn't registered is, and so panics when its id is reserved
+// or belongs to another codec
+func (c *Configuration) Codec(codec Codec) *Configuration {
+	if registered, exists := getCodec(codec.Id()); exists == false {
+		RegisterCodec(codec)
+	} else if registered != codec {
+		panic("nabu: codec id " + strconv.Itoa(int(codec.Id())) + " belongs to another codec")
+	}
+	c.codec = codec
+	return c
+}
+
+// Keeps documents encoded in memory, decoding them via the configured
+// IntFactory or StringFactory when they are retrieved. Up to cacheSize
+// decoded documents are kept, evicting the least recently used. Indexes
+// remain fully in memory
+func (c *Configuration) LazyHydration(cacheSize int) *Configuration {
+	c.lazy = true
+	c.cacheSize = cacheSize
+	return c
+}
+
+// The number of sort index entries Query.Iterate scans while holding
+// read locks. Smaller chunks let writers in more often. Sizes below 1
+// are treated as 1
+func (c *Configuration) IterationChunkSize(size int) *Configuration {
+	if size < 1 {
+		size = 1
+	}
+	c.iterationChunkSize = size
+	return c
+}
+
+// How often expired documents are removed from the database. Expired
+// documents are excluded from queries regardless. 0 disables the removal
+func (c *Configuration) ExpiryInterval(interval time.Duration) *Configuration {
+	c.expiryInterval = interval
+	return c
+}
+
+// Rejects updates of documents whose version is older than the stored
+// document's version, Update returns ErrStaleVersion instead
+func (c *Configuration) RejectStaleVersions() *Configuration {
+	c.rejectStaleVersions = true
+	return c
+}
+
+// The approximate number of bytes the database should use. Once exceeded,
+// updates are either rejected, with ErrMemoryLimit, or merely logged.
+// Usage is measured at most once per second. 0 disables the limit
+func (c *Configuration) MemoryLimit(bytes int, reject bool) *Configuration {
+	c.memoryLimit = bytes
+	c.rejectOverMemoryLimit = reject
+	return c
+}
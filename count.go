@@ -0,0 +1,114 @@
+package nabu
+
+import (
+	"github.com/karlseguin/nabu/indexes"
+	"github.com/karlseguin/nabu/key"
+)
+
+// Have Count return the exact number of matches, rather than stopping
+// at the configured MaxTotal
+func (q *NormalQuery) Exact() Query {
+	q.exact = true
+	return q
+}
+
+// Counts the matching documents without collecting their ids. Without
+// conditions, the sort index's length is used. Otherwise, the smaller of
+// the first condition and the sort index is walked and checked against
+// the rest. Unless Exact was called, counting stops at MaxTotal
+func (q *NormalQuery) Count() int {
+	if q.snapshot == nil {
+		q.db.txnLock.RLock()
+		defer q.db.txnLock.RUnlock()
+	}
+	defer q.reset()
+	if q.err != nil {
+		return 0
+	}
+	q.now = q.db.expiry.now()
+	upto := q.db.maxTotal
+	if q.exact {
+		upto = int(^uint(0) >> 1)
+	}
+	count := q.count(upto)
+	if count > upto {
+		return upto
+	}
+	return count
+}
+
+func (q *NormalQuery) count(upto int) int {
+	q.useComposites()
+	conditionCount := q.conditionCount
+	if q.dynamicSort != nil {
+		q.prepareConditions()
+		defer q.conditions[:conditionCount].RUnlock()
+		count := 0
+		for i := 0; i < len(q.dynamicSort) && count < upto; i++ {
+			if q.matches(key.Type(q.dynamicSort[i])) {
+				count++
+			}
+		}
+		return count
+	}
+
+	var sort RankedContainer = q.sort
+	q.sort.RLock()
+	if q.sortCondition != nil {
+		q.sortCondition.On(q.sort)
+		q.sortLength = q.sortCondition.Len()
+		sort = q.sortCondition
+	} else {
+		q.sortLength = q.sort.Len()
+	}
+	q.sort.RUnlock()
+
+	if conditionCount == 0 && q.now == 0 && q.distinctBy == "" {
+		return q.sortLength
+	}
+	if conditionCount > 0 {
+		q.prepareConditions()
+		defer q.conditions[:conditionCount].RUnlock()
+		if first := q.conditions[0]; first.CanIterate() && first.Len() < q.sortLength {
+			return q.countByIndex(sort, upto)
+		}
+	}
+	return q.countBySort(upto)
+}
+
+// Walks the first condition, counting the ids which match the other
+// conditions and are in the sort index
+func (q *NormalQuery) countByIndex(sort RankedContainer, upto int) int {
+	count := 0
+	q.sort.RLock()
+	defer q.sort.RUnlock()
+	iterator := q.conditions[0].Iterator()
+	defer iterator.Close()
+	for id := iterator.Current(); id != key.NULL && count < upto; id = iterator.Next() {
+		if _, exists := sort.Score(id); exists && q.matches(id) {
+			count++
+		}
+	}
+	return count
+}
+
+// Walks the sort index, counting the ids which match the conditions
+func (q *NormalQuery) countBySort(upto int) int {
+	count := 0
+	var iterator indexes.Iterator
+	if q.desc {
+		iterator = q.sort.Backwards()
+	} else {
+		iterator = q.sort.Forwards()
+	}
+	defer iterator.Close()
+	if q.sortCondition != nil {
+		iterator.Range(q.sortCondition.Range()).Offset(0)
+	}
+	for id := iterator.Current(); id != key.NULL && count < upto; id = iterator.Next() {
+		if q.matches(id) {
+			count++
+		}
+	}
+	return count
+}
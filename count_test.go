@@ -0,0 +1,59 @@
+package nabu
+
+import (
+	"github.com/karlseguin/gspec"
+	"testing"
+	"time"
+)
+
+func TestCountWithNoConditionsUsesTheSortLength(t *testing.T) {
+	spec := gspec.New(t)
+	db := New(SmallConfig())
+	defer db.Close()
+	makeIndex(db, "created", 1, 2, 3, 4, 5, 6, 7)
+	spec.Expect(db.Query("created").Count()).ToEqual(7)
+	spec.Expect(db.Query("created").Where(GT("created", 5)).Count()).ToEqual(2)
+	spec.Expect(len(db.queryPool)).ToEqual(1)
+}
+
+func TestCountByIndex(t *testing.T) {
+	spec := gspec.New(t)
+	db := New(SmallConfig())
+	defer db.Close()
+	makeIndex(db, "created", 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12)
+	makeSet(db, "city=dune", 2, 4, 6, 13)
+	makeIndex(db, "age", 4, 6, 7)
+	spec.Expect(db.Query("created").Set("city", "dune").Count()).ToEqual(3)
+	spec.Expect(db.Query("created").Set("city", "dune").Where(GT("age", 0)).Count()).ToEqual(2)
+	spec.Expect(db.Query("created").Set("city", "dune").Where(LT("created", 5)).Count()).ToEqual(2)
+}
+
+func TestCountBySort(t *testing.T) {
+	spec := gspec.New(t)
+	db := New(SmallConfig())
+	defer db.Close()
+	makeIndex(db, "created", 1, 2, 3, 4, 5, 6)
+	makeIndex(db, "age", 2, 3, 4, 5, 6, 7, 8, 9)
+	spec.Expect(db.Query("created").Where(GT("age", 3)).Count()).ToEqual(3)
+	spec.Expect(db.DynamicQuery([]uint{1, 3, 4, 6}).Where(GT("age", 3)).Count()).ToEqual(2)
+}
+
+func TestCountIsCappedUnlessExact(t *testing.T) {
+	spec := gspec.New(t)
+	db := New(SmallConfig().MaxTotal(3))
+	defer db.Close()
+	makeIndex(db, "created", 1, 2, 3, 4, 5, 6)
+	makeIndex(db, "age", 1, 2, 3, 4, 5, 6)
+	spec.Expect(db.Query("created").Count()).ToEqual(3)
+	spec.Expect(db.Query("created").Where(GT("age", 0)).Count()).ToEqual(3)
+	spec.Expect(db.Query("created").Where(GT("age", 0)).Exact().Count()).ToEqual(6)
+}
+
+func TestCountExcludesExpiredDocuments(t *testing.T) {
+	spec := gspec.New(t)
+	db := New(SmallConfig())
+	defer db.Close()
+	db.Update(NewDoc(1, map[string]int{"created": 1}))
+	db.Update(NewExpiringDoc(2, time.Now().Add(-time.Minute), map[string]int{"created": 2}))
+	spec.Expect(db.Query("created").Count()).ToEqual(1)
+}
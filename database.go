@@ -3,51 +3,91 @@ package nabu
 
 import (
 	"bytes"
+	"encoding/binary"
 	"encoding/json"
+	"errors"
 	"github.com/karlseguin/nabu/indexes"
 	"github.com/karlseguin/nabu/key"
 	"github.com/karlseguin/nabu/storage"
 	"log"
+	"strconv"
 	"sync"
+	"sync/atomic"
+	"time"
 )
 
 /*
 Factory is used to recreate documents from the persisted
-representations. Data is a []byte array which was encoded using
-encoding/json. If you have a single type of document, a simple
-factory will suffice:
-
-   func factory(id key.Type, data []byte) nabu.Document {
-     product := new(Product)
-     if err := json.Unmarshal(data, &tree); err != nil {
-       panic(err)
-     }
-     return product
-   }
-
-If you have multiple documents, you'll either use the id or
-information within the data itself to determine the type.
-(whether or not you can use the id depends on whether your system
-allows you to infer the type based on the id).
-
-   func factory(id uint, data []byte) nabu.Document {
-     var m map[string]interface{}
-     if err := json.Unmarshal(data, &m); err != nil {
-       panic(err)
-     }
-     type := m["type"].(string)
-     if type == "Product" {
-       ...
-     } else if .... {
-       ...
-     }
-   }
+representations. Data is a []byte array which was encoded using the
+configured Codec (encoding/json unless configured otherwise). If you
+have a single type of document, a simple factory will suffice:
+
+	func factory(id uint, t string, data []byte) nabu.Document {
+	  product := new(Product)
+	  if err := json.Unmarshal(data, product); err != nil {
+	    panic(err)
+	  }
+	  return product
+	}
+
+If you have multiple documents, you'll either use t (the type
+exposed by the document's meta), the id or information within the
+data itself to determine the type.
+
+	func factory(id uint, t string, data []byte) nabu.Document {
+	  if t == "Product" {
+	    ...
+	  } else if .... {
+	    ...
+	  }
+	}
+
+When the codec has changed over time, documents are still encoded
+with the codec which was configured when they were written. A
+CodecIntFactory or CodecStringFactory is also given that codec to
+decode the data with:
+
+	func factory(id uint, t string, data []byte, codec nabu.Codec) nabu.Document {
+	  product := new(Product)
+	  if err := codec.Decode(data, product); err != nil {
+	    panic(err)
+	  }
+	  return product
+	}
 */
 type IntFactory func(id uint, t string, data []byte) Document
 type StringFactory func(stringId string, id uint, t string, data []byte) Document
+type CodecIntFactory func(id uint, t string, data []byte, codec Codec) Document
+type CodecStringFactory func(stringId string, id uint, t string, data []byte, codec Codec) Document
 
 var EmptyIndex = indexes.NewEmpty("<__></__>db_empty")
 
+var (
+	// Returned when an update is rejected because of the document's version
+	ErrStaleVersion = errors.New("stale document version")
+	// Returned when a persisted document was written with an unregistered codec
+	ErrUnknownCodec = errors.New("unknown codec")
+	// Returned when a persisted document can't be parsed
+	ErrCorruptValue = errors.New("corrupt value")
+	// Returned by Decode when neither factory is configured
+	ErrNoFactory = errors.New("no factory configured")
+	// Returned by Decode when the document's id isn't the one it was given
+	ErrIdMismatch = errors.New("document id does not match")
+)
+
+// Marks a persisted value as having a codec id, as opposed
+// to the legacy type|json format
+const VALUE_MARKER = 0
+
+// How an update validates the document's version against the stored one
+type versionCheck int
+
+const (
+	noVersionCheck versionCheck = iota
+	staleVersionCheck
+	exactVersionCheck
+)
+
 // Database is the primary point of interaction with Nabu
 type Database struct {
 	loading bool
@@ -55,34 +95,50 @@ type Database struct {
 	queryPool       chan *NormalQuery
 	storage         storage.Storage
 	indexLock       sync.RWMutex
+	txnLock         sync.RWMutex
 	idMap           *IdMap
 	sortedResults   chan *SortedResult
 	indexes         map[string]indexes.Index
 	unsortedResults chan *UnsortedResult
 	Buckets         map[int]*Bucket
+	expiry          *Expiry
+	dimensions      *Dimensions
+	uniques         *Uniques
+	memory          *memoryMeter
+	cache           *documentCache
+	stop            chan struct{}
+	closer          sync.Once
+	snapshots       int32
+	generation      uint64
+	versions        map[string]uint64
+	statics         map[string]struct{}
+	parent          *Database
+	namespaceLock   sync.Mutex
+	namespaces      map[string]*Namespace
+	compositeLock   sync.RWMutex
+	allComposites   []*composite
 }
 
 // Creates a new Database instance. Unless configured to SkipLoad, data from
 // the storage path will be restored
 func New(c *Configuration) *Database {
-	db := &Database{
-		Configuration:   c,
-		indexes:         make(map[string]indexes.Index),
-		queryPool:       make(chan *NormalQuery, c.queryPoolSize),
-		Buckets:         make(map[int]*Bucket, c.bucketCount),
-		sortedResults:   make(chan *SortedResult, c.sortedResultPoolSize),
-		unsortedResults: make(chan *UnsortedResult, c.unsortedResultPoolSize),
-		idMap:           newIdMap(),
+	if c.lazy && c.iFactory == nil && c.sFactory == nil {
+		panic("nabu: LazyHydration requires an IntFactory or a StringFactory")
 	}
+	db := newDatabase(c)
+	db.queryPool = make(chan *NormalQuery, c.queryPoolSize)
+	db.sortedResults = make(chan *SortedResult, c.sortedResultPoolSize)
+	db.unsortedResults = make(chan *UnsortedResult, c.unsortedResultPoolSize)
 	if c.persist || c.skipLoad == false {
-		db.storage = storage.New(c.dbPath)
+		path := c.dbPath
+		if len(c.restoreFrom) != 0 && c.skipLoad == false {
+			path = restoreBackup(c)
+		}
+		db.storage = storage.New(path)
 	} else {
 		db.storage = storage.NullStorage
 	}
 
-	for i := 0; i < int(c.bucketCount); i++ {
-		db.Buckets[i] = &Bucket{Lookup: make(map[key.Type]Document)}
-	}
 	for i := 0; i < c.queryPoolSize; i++ {
 		newQuery(db) //it automatically enqueues itself
 	}
@@ -96,33 +152,85 @@ func New(c *Configuration) *Database {
 	if c.skipLoad == false {
 		db.restore()
 		if c.persist == false {
+			db.restoreNamespaces()
 			db.storage.Close()
 			db.storage = storage.NullStorage
 		}
 	}
+	if c.expiryInterval > 0 {
+		go db.reap()
+	}
+	return db
+}
+
+// The documents and indexes of a database, without its pools or storage
+func newDatabase(c *Configuration) *Database {
+	db := &Database{
+		Configuration: c,
+		indexes:       make(map[string]indexes.Index),
+		versions:      make(map[string]uint64),
+		statics:       make(map[string]struct{}),
+		Buckets:       make(map[int]*Bucket, c.bucketCount),
+		idMap:         newIdMap(),
+		expiry:        newExpiry(),
+		dimensions:    newDimensions(),
+		uniques:       newUniques(),
+		memory:        new(memoryMeter),
+		cache:         newDocumentCache(c.cacheSize),
+		stop:          make(chan struct{}),
+		namespaces:    make(map[string]*Namespace),
+		allComposites: c.composites,
+	}
+	for i := 0; i < int(c.bucketCount); i++ {
+		db.Buckets[i] = &Bucket{Lookup: make(map[key.Type]Document)}
+	}
 	return db
 }
 
 // Generate a Query object against the specified sort index
 func (d *Database) Query(indexName string) Query {
+	if d.schema != nil && d.schema.declares(indexName) == false {
+		return &EmptyQuery{err: &SchemaError{Index: indexName}}
+	}
 	d.indexLock.RLock()
 	index, exists := d.indexes[indexName].(indexes.Ranked)
 	d.indexLock.RUnlock()
 	if exists == false {
 		return emptyQuery
 	}
-	q := <-d.queryPool
+	q := d.query()
 	q.sort = index
 	return q
 }
 
 // Generate a DynamicQuery for the specified ids
 func (d *Database) DynamicQuery(ids []uint) Query {
-	q := <-d.queryPool
+	q := d.query()
 	q.dynamicSort = ids
 	return q
 }
 
+// Checks a query out of the pool, which is shared with namespaces
+func (d *Database) query() *NormalQuery {
+	q := <-d.queryPool
+	q.db = d
+	return q
+}
+
+// Checks a sorted result out of the pool, which is shared with namespaces
+func (d *Database) sortedResult() *SortedResult {
+	r := <-d.sortedResults
+	r.db = d
+	return r
+}
+
+// Checks an unsorted result out of the pool, which is shared with namespaces
+func (d *Database) unsortedResult() *UnsortedResult {
+	r := <-d.unsortedResults
+	r.db = d
+	return r
+}
+
 func (d *Database) StringContains(indexName string, id string) bool {
 	typed := d.idMap.get(id, false)
 	if typed == key.NULL {
@@ -134,7 +242,14 @@ func (d *Database) StringContains(indexName string, id string) bool {
 func (d *Database) Contains(indexName string, id uint) bool {
 	return d.KeyContains(indexName, key.Type(id))
 }
+
+// Whether the index contains the unexpired document
 func (d *Database) KeyContains(indexName string, id key.Type) bool {
+	d.txnLock.RLock()
+	defer d.txnLock.RUnlock()
+	if now := d.expiry.now(); now != 0 && d.expiry.expired(id, now) {
+		return false
+	}
 	d.indexLock.RLock()
 	index, exists := d.indexes[indexName].(indexes.Index)
 	d.indexLock.RUnlock()
@@ -148,6 +263,8 @@ func (d *Database) KeyContains(indexName string, id key.Type) bool {
 
 // Retrieves a document by id
 func (d *Database) Get(id uint) Document {
+	d.txnLock.RLock()
+	defer d.txnLock.RUnlock()
 	return d.get(key.Type(id))
 }
 
@@ -157,6 +274,8 @@ func (d *Database) StringGet(id string) Document {
 	if typed == key.NULL {
 		return nil
 	}
+	d.txnLock.RLock()
+	defer d.txnLock.RUnlock()
 	return d.get(typed)
 }
 
@@ -164,6 +283,8 @@ func (d *Database) StringGet(id string) Document {
 func (d *Database) StringGets(ids []string) []Document {
 	documents := make([]Document, len(ids))
 	index := 0
+	d.txnLock.RLock()
+	defer d.txnLock.RUnlock()
 	for _, id := range ids {
 		typed := d.idMap.get(id, false)
 		if typed != key.NULL {
@@ -174,26 +295,103 @@ func (d *Database) StringGets(ids []string) []Document {
 	return documents[:index]
 }
 
-// Inserts or updates the document
-func (d *Database) Update(doc Document) {
+// Inserts or updates the document. When configured to RejectStaleVersions,
+// ErrStaleVersion is returned if the stored document has a newer version
+func (d *Database) Update(doc Document) error {
+	if err := d.checkMemory(); err != nil {
+		return err
+	}
+	d.txnLock.RLock()
+	defer d.txnLock.RUnlock()
+	_, err := d.update(doc, d.updateCheck(), 0)
+	return err
+}
+
+// Inserts or updates the document only if the stored document's version
+// is expectedVersion (or, for a new document, if expectedVersion is 0).
+// Returns ErrStaleVersion otherwise
+func (d *Database) CompareAndUpdate(doc Document, expectedVersion uint64) error {
+	if err := d.checkMemory(); err != nil {
+		return err
+	}
+	d.txnLock.RLock()
+	defer d.txnLock.RUnlock()
+	_, err := d.update(doc, exactVersionCheck, expectedVersion)
+	return err
+}
+
+// Inserts or updates the document, returning the document it replaced
+func (d *Database) update(doc Document, check versionCheck, expectedVersion uint64) (Document, error) {
 	if doc == nil {
-		return
+		return nil, nil
 	}
 	meta := newMeta(d, true)
 
-	doc.ReadMeta(meta)
+	d.readMeta(doc, meta)
+	if meta.err != nil {
+		return nil, meta.err
+	}
+	if len(meta.composites) != 0 {
+		d.learnComposites(meta)
+	}
+
+	persist := d.loading == false && d.persist
+	var serialized []byte
+	if persist || d.lazy {
+		var err error
+		if serialized, err = serializeValue(d.codec, meta.t, doc); err != nil {
+			return nil, err
+		}
+	}
 
 	id, stringId := meta.getId()
-	bucket := d.getBucket(id)
-	bucket.Lock()
+	bucket := d.lockBucket(id)
 	old, isUpdate := bucket.Lookup[id]
-	bucket.Lookup[id] = doc
-	bucket.Unlock()
-
 	oldMeta := newMeta(d, false)
 	if isUpdate {
-		old.ReadMeta(oldMeta)
+		if old = d.hydrate(id, old); old != nil {
+			d.readMeta(old, oldMeta)
+		}
 	}
+	if (check == staleVersionCheck && meta.version < oldMeta.version) ||
+		(check == exactVersionCheck && oldMeta.version != expectedVersion) {
+		bucket.Unlock()
+		return nil, ErrStaleVersion
+	}
+	if d.loading {
+		d.uniques.take(id, meta.uniques)
+	} else if d.uniques.claim(id, meta.uniques) == false {
+		bucket.Unlock()
+		return nil, ErrUniqueConflict
+	}
+	stored := doc
+	if d.lazy {
+		encoded := &encodedDocument{stringId, serialized}
+		d.cache.set(id, encoded, doc)
+		stored = encoded
+	}
+	if isUpdate {
+		bucket.memory -= documentMemory(bucket.Lookup[id])
+	}
+	bucket.Lookup[id] = stored
+	bucket.memory += documentMemory(stored)
+	bucket.Unlock()
+	d.reindex(id, meta, oldMeta)
+
+	if persist {
+		idBuffer := id.Serialize()
+		defer idBuffer.Close()
+		d.storage.PutDocument(idBuffer.Bytes(), serialized)
+		if len(stringId) != 0 {
+			d.storage.PutMapping(stringId, idBuffer.Bytes())
+		}
+	}
+	return old, nil
+}
+
+// Moves the document's entries from the indexes oldMeta names to those
+// meta names. Unique values are assumed to already be claimed
+func (d *Database) reindex(id key.Type, meta *Meta, oldMeta *Meta) {
 	for name, score := range meta.sortedInts {
 		delete(oldMeta.sortedInts, name)
 		d.getOrCreateSortedIntIndex(name).SetInt(id, score)
@@ -212,7 +410,7 @@ func (d *Database) Update(doc Document) {
 
 	for name, _ := range meta.setStrings {
 		delete(oldMeta.setStrings, name)
-		d.getOrCreateSetStringIndex(name).Set(id)
+		d.addToSet(name, id, d.getOrCreateSetStringIndex)
 	}
 	for name, _ := range oldMeta.setStrings {
 		d.safeDelete(name, id)
@@ -220,27 +418,51 @@ func (d *Database) Update(doc Document) {
 
 	for name, _ := range meta.bigSetStrings {
 		delete(oldMeta.bigSetStrings, name)
-		d.getOrCreateBigSetStringIndex(name).Set(id)
+		d.addToSet(name, id, d.getOrCreateBigSetStringIndex)
 	}
 	for name, _ := range oldMeta.bigSetStrings {
 		d.safeDelete(name, id)
 	}
 
-	if d.loading == false && d.persist {
-		idBuffer := id.Serialize()
-		defer idBuffer.Close()
-		d.storage.PutDocument(idBuffer.Bytes(), serializeValue(meta.t, doc))
-		if len(stringId) != 0 {
-			d.storage.PutMapping(stringId, idBuffer.Bytes())
+	for name, value := range meta.dimensions {
+		delete(oldMeta.dimensions, name)
+		d.dimensions.set(name, id, value)
+	}
+	for name, _ := range oldMeta.dimensions {
+		d.dimensions.remove(name, id)
+	}
+	for name, value := range oldMeta.uniques {
+		if meta.uniques[name] != value {
+			d.uniques.release(name, value, id)
 		}
 	}
+
+	if meta.expires.IsZero() {
+		d.expiry.remove(id)
+	} else {
+		d.expiry.set(id, meta.expires)
+	}
+}
+
+func (d *Database) updateCheck() versionCheck {
+	if d.rejectStaleVersions {
+		return staleVersionCheck
+	}
+	return noVersionCheck
 }
 
 // Removes the document. Safe to call even if the document
 // does not exists.
 func (d *Database) Remove(doc Document) {
+	d.txnLock.RLock()
+	defer d.txnLock.RUnlock()
+	d.remove(doc)
+}
+
+// Removes the document, returning the document which was stored
+func (d *Database) remove(doc Document) Document {
 	meta := newMeta(d, false)
-	doc.ReadMeta(meta)
+	d.readMeta(doc, meta)
 	id, stringId := meta.getId()
 	for name, _ := range meta.sortedInts {
 		d.safeDelete(name, id)
@@ -254,10 +476,24 @@ func (d *Database) Remove(doc Document) {
 	for name, _ := range meta.bigSetStrings {
 		d.safeDelete(name, id)
 	}
-	bucket := d.getBucket(id)
-	bucket.Lock()
+	for name, _ := range meta.dimensions {
+		d.dimensions.remove(name, id)
+	}
+	for name, value := range meta.uniques {
+		d.uniques.release(name, value, id)
+	}
+	bucket := d.lockBucket(id)
+	old, exists := bucket.Lookup[id]
 	delete(bucket.Lookup, id)
+	if exists {
+		bucket.memory -= documentMemory(old)
+	}
 	bucket.Unlock()
+	d.expiry.remove(id)
+	if d.lazy {
+		old = d.hydrate(id, old)
+		d.cache.remove(id)
+	}
 
 	if d.loading == false {
 		idBuffer := id.Serialize()
@@ -268,6 +504,7 @@ func (d *Database) Remove(doc Document) {
 			d.storage.RemoveMapping(stringId)
 		}
 	}
+	return old
 }
 
 // Removes to the document by id. Safe to call even if the
@@ -285,12 +522,26 @@ func (d *Database) RemoveByStringId(id string) {
 	}
 }
 
-// Closes the database
+// Closes the database, and its namespaces
 func (d *Database) Close() error {
+	d.shutdown()
 	return d.storage.Close()
 }
 
+// Stops the background work of the database and its namespaces
+func (d *Database) shutdown() {
+	d.closer.Do(func() { close(d.stop) })
+	for _, namespace := range d.openNamespaces() {
+		namespace.db.shutdown()
+	}
+}
+
 func (d *Database) BulkLoadSortedString(name string, ids []string) {
+	if d.bulkLoadable(name, SortedStringKind) == false {
+		return
+	}
+	d.txnLock.RLock()
+	defer d.txnLock.RUnlock()
 	index, ok := d.getOrCreateSortedStringIndex(name).(*indexes.SortedStrings)
 	if ok == false {
 		log.Println(name + " could not be bulk loaded")
@@ -300,23 +551,179 @@ func (d *Database) BulkLoadSortedString(name string, ids []string) {
 		keys[index] = d.idMap.get(id, true)
 	}
 	index.BulkLoad(keys)
+	d.indexLock.Lock()
+	d.statics[name] = struct{}{}
+	d.indexLock.Unlock()
+}
+
+// Replaces the named index with a sorted int index of the ids and their
+// scores. Ids ordered by score are loaded in linear time. The index
+// is persisted. Logs, and does nothing, when ids and scores aren't of
+// the same length
+func (d *Database) LoadSortedInts(name string, ids []uint, scores []int) {
+	if d.bulkLoadable(name, SortedIntKind) == false {
+		return
+	}
+	if len(ids) != len(scores) {
+		log.Printf("nabu: %s has %d ids but %d scores", name, len(ids), len(scores))
+		return
+	}
+	// built before blocking writers, which only wait for the swap
+	keys := toKeys(ids)
+	index := newSortedInts(name, keys, scores)
+	d.txnLock.Lock()
+	defer d.txnLock.Unlock()
+	d.swapIndex(name, index)
+	d.persistSort(name, keys, scores, true)
+}
+
+// Adds the ids to the end of the sort index. Ids already in the index
+// are moved. The index is persisted
+func (d *Database) AppendSort(name string, ids []uint) {
+	d.extendSort(name, toKeys(ids), false)
+}
+
+// Adds the ids to the start of the sort index. Ids already in the index
+// are moved. The index is persisted
+func (d *Database) PrependSort(name string, ids []uint) {
+	d.extendSort(name, toKeys(ids), true)
+}
+
+func (d *Database) extendSort(name string, ids []key.Type, prepend bool) {
+	d.txnLock.Lock()
+	defer d.txnLock.Unlock()
+
+	index, _ := d.getIndex(name)
+	if static, ok := index.(*indexes.SortedStrings); ok {
+		if d.bulkLoadable(name, SortedStringKind) == false {
+			return
+		}
+		existing, _ := extendIds(static.Ids(), nil, ids, prepend)
+		d.loadSortedStrings(name, existing)
+		return
+	}
+	if d.bulkLoadable(name, SortedIntKind) == false {
+		return
+	}
+	existing, scores := make([]key.Type, 0), make([]int, 0)
+	if dynamic, ok := index.(*indexes.SortedInts); ok {
+		existing, scores = dynamic.Ordered()
+	}
+	existing, scores = extendIds(existing, scores, ids, prepend)
+	d.loadSortedInts(name, existing, scores)
+}
+
+func (d *Database) loadSortedInts(name string, ids []key.Type, scores []int) {
+	d.swapIndex(name, newSortedInts(name, ids, scores))
+	d.persistSort(name, ids, scores, true)
+}
+
+func newSortedInts(name string, ids []key.Type, scores []int) *indexes.SortedInts {
+	index := indexes.NewSortedInts(name)
+	index.BulkLoad(ids, scores)
+	return index
+}
+
+func (d *Database) loadSortedStrings(name string, ids []key.Type) {
+	index := indexes.NewSortedStrings(name)
+	index.BulkLoad(ids)
+	d.swapIndex(name, index)
+	d.persistSort(name, ids, nil, false)
+}
+
+// Logs, and returns false, when the schema doesn't declare
+// the index as kind
+func (d *Database) bulkLoadable(name string, kind IndexKind) bool {
+	if d.schema == nil {
+		return true
+	}
+	if err := d.schema.check(name, kind); err != nil {
+		log.Println(err)
+		return false
+	}
+	return true
+}
+
+// Replaces (or adds) the named, bulk loaded, index
+func (d *Database) swapIndex(name string, index indexes.Index) {
+	d.indexLock.Lock()
+	defer d.indexLock.Unlock()
+	d.indexes[name] = index
+	d.versions[name] = d.generation
+	d.statics[name] = struct{}{}
+}
+
+func (d *Database) persistSort(name string, ids []key.Type, scores []int, ranged bool) {
+	if d.loading == false && d.persist {
+		d.storage.PutSort(name, serializeSort(ids, scores, ranged))
+	}
 }
 
 // Removes to the document by id. Safe to call even if the
 // id doesn't exist
 func (d *Database) removeByTypedId(id key.Type) {
-	doc := d.get(id)
+	doc := d.lookup(id)
 	if doc != nil {
 		d.Remove(doc)
 	}
 }
 
-// Gets a document from the given bucket
+// Gets a document, unless it has expired
 func (d *Database) get(id key.Type) Document {
+	if now := d.expiry.now(); now != 0 && d.expiry.expired(id, now) {
+		return nil
+	}
+	return d.lookup(id)
+}
+
+// Gets a document from the given bucket
+func (d *Database) lookup(id key.Type) Document {
+	bucket := d.getBucket(id)
+	bucket.RLock()
+	doc := bucket.Lookup[id]
+	bucket.RUnlock()
+	return d.hydrate(id, doc)
+}
+
+// Periodically removes expired documents until the database is closed
+func (d *Database) reap() {
+	ticker := time.NewTicker(d.expiryInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			d.removeExpired()
+		case <-d.stop:
+			return
+		}
+	}
+}
+
+// Removes all expired documents, including from storage
+func (d *Database) removeExpired() {
+	now := time.Now().UnixNano()
+	for _, id := range d.expiry.expiredIds(now) {
+		d.removeIfExpired(id, now)
+	}
+}
+
+// Removes the document unless it was renewed since it expired. Writers
+// are locked out so that a renewing update can't land in between
+func (d *Database) removeIfExpired(id key.Type, now int64) {
+	d.txnLock.Lock()
+	defer d.txnLock.Unlock()
 	bucket := d.getBucket(id)
 	bucket.RLock()
-	defer bucket.RUnlock()
-	return bucket.Lookup[id]
+	doc, expired := bucket.Lookup[id], d.expiry.expired(id, now)
+	bucket.RUnlock()
+	if expired == false {
+		return
+	}
+	if doc = d.hydrate(id, doc); doc != nil {
+		d.remove(doc)
+	} else {
+		d.expiry.remove(id)
+	}
 }
 
 // Gets the document's bucket
@@ -324,6 +731,18 @@ func (d *Database) getBucket(key key.Type) *Bucket {
 	return d.Buckets[key.Bucket(d.bucketCount)]
 }
 
+// Locks the document's bucket for writing. A bucket shared with an open
+// snapshot is copied first
+func (d *Database) lockBucket(id key.Type) *Bucket {
+	bucket := d.getBucket(id)
+	bucket.Lock()
+	if bucket.version < d.generation && d.snapshotted() {
+		bucket.Lookup = bucket.copyLookup()
+		bucket.version = d.generation
+	}
+	return bucket
+}
+
 func (d *Database) getIndex(name string) (indexes.Index, bool) {
 	d.indexLock.RLock()
 	defer d.indexLock.RUnlock()
@@ -331,83 +750,228 @@ func (d *Database) getIndex(name string) (indexes.Index, bool) {
 	return index, exists
 }
 
+// Gets an index which is safe to modify. An index shared with an open
+// snapshot is copied first
+func (d *Database) getWritableIndex(name string) (indexes.Index, bool) {
+	d.indexLock.RLock()
+	index, exists := d.indexes[name]
+	shared := exists && d.versions[name] < d.generation && d.snapshotted()
+	d.indexLock.RUnlock()
+	if shared == false {
+		return index, exists
+	}
+
+	d.indexLock.Lock()
+	defer d.indexLock.Unlock()
+	index = d.indexes[name]
+	if d.versions[name] < d.generation {
+		index = index.Clone(name)
+		d.indexes[name] = index
+		d.versions[name] = d.generation
+	}
+	return index, true
+}
+
+// Whether any snapshot is open
+func (d *Database) snapshotted() bool {
+	return atomic.LoadInt32(&d.snapshots) > 0
+}
+
 // Loads documents and indexes from the storage engine
 func (d *Database) restore() {
 	d.loading = true
 
+	d.storage.IterateSorts(func(name string, value []byte) {
+		sort, err := deserializeSort(value)
+		if err != nil {
+			log.Printf("nabu: skipping sort %s: %v", name, err)
+			return
+		}
+		if sort.Ranged {
+			d.loadSortedInts(name, sort.Ids, sort.Scores)
+		} else {
+			d.loadSortedStrings(name, sort.Ids)
+		}
+	})
+
 	if d.iFactory != nil {
-		d.storage.IterateDocuments(func(id, byteValue []byte){
-			t, value := deserializeValue(byteValue)
-			d.Update(d.iFactory(key.Deserialize(id), t, value))
+		d.storage.IterateDocuments(func(rawId, byteValue []byte) {
+			id := key.Deserialize(rawId)
+			t, codec, value, err := deserializeValue(byteValue)
+			if err != nil {
+				log.Printf("nabu: skipping document %d: %v", id, err)
+				return
+			}
+			d.restoreDocument(d.iFactory(id, t, value, codec), strconv.FormatUint(uint64(id), 10))
 		})
 	} else {
 		lookup := make(map[uint]string)
-		d.storage.IterateMappings(func(stringId string, id []byte){
+		d.storage.IterateMappings(func(stringId string, id []byte) {
 			lookup[key.Deserialize(id)] = string(stringId)
 		})
 		d.idMap.load(lookup)
 
-		d.storage.IterateDocuments(func(rawId, byteValue []byte){
+		d.storage.IterateDocuments(func(rawId, byteValue []byte) {
 			id := key.Deserialize(rawId)
-			t, value := deserializeValue(byteValue)
-			d.Update(d.sFactory(lookup[id], id, t, value))
+			t, codec, value, err := deserializeValue(byteValue)
+			if err != nil {
+				log.Printf("nabu: skipping document %q: %v", lookup[id], err)
+				return
+			}
+			d.restoreDocument(d.sFactory(lookup[id], id, t, value, codec), strconv.Quote(lookup[id]))
 		})
 	}
 	d.loading = false
 }
 
+// Adds a persisted document. Unlike Update, the memory limit isn't
+// enforced, since rejecting a document would silently drop it
+func (d *Database) restoreDocument(doc Document, description string) {
+	if _, err := d.update(doc, noVersionCheck, 0); err != nil {
+		log.Printf("nabu: skipping document %s: %v", description, err)
+	}
+}
+
+// Creates a document from data encoded with codec, via the configured
+// IntFactory (in which case id must be an integer) or StringFactory.
+// Returns ErrIdMismatch when the document's meta gives it another id.
+// String ids aren't allocated an internal id until the document is
+// updated, the StringFactory is given 0 for ids new to the database
+func (d *Database) Decode(id string, t string, data []byte, codec Codec) (Document, error) {
+	if d.iFactory != nil {
+		intId, err := strconv.ParseUint(id, 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		doc := d.iFactory(uint(intId), t, data, codec)
+		if doc != nil {
+			if metaId, _ := readId(doc); metaId != key.Type(intId) {
+				return nil, ErrIdMismatch
+			}
+		}
+		return doc, nil
+	}
+	if d.sFactory != nil {
+		internalId := d.idMap.get(id, false)
+		if internalId == key.NULL {
+			internalId = 0
+		}
+		doc := d.sFactory(id, uint(internalId), t, data, codec)
+		if doc != nil {
+			if _, metaId := readId(doc); metaId != id {
+				return nil, ErrIdMismatch
+			}
+		}
+		return doc, nil
+	}
+	return nil, ErrNoFactory
+}
+
+// Whether documents are identified by string ids, which is the case
+// when a StringFactory, and no IntFactory, is configured
+func (d *Database) StringIds() bool {
+	return d.iFactory == nil && d.sFactory != nil
+}
+
+// The ids the document's meta gives it, without allocating an
+// internal id for a string id
+func readId(doc Document) (key.Type, string) {
+	meta := newMeta(nil, false)
+	doc.ReadMeta(meta)
+	return meta.getId()
+}
+
 // Callback used to load indexes from index names
 func (d *Database) LoadIndexes(conditions Conditions) {
 	d.indexLock.RLock()
 	defer d.indexLock.RUnlock()
+	loadIndexes(d.indexes, conditions)
+}
+
+func loadIndexes(lookup map[string]indexes.Index, conditions Conditions) {
 	for _, condition := range conditions {
 		if multi, ok := condition.(MultiCondition); ok {
 			for _, indexName := range multi.IndexNames() {
-				d.associateIndexWithCondition(condition, indexName)
+				associateIndexWithCondition(lookup, condition, indexName)
 			}
 		} else {
-			d.associateIndexWithCondition(condition, condition.IndexName())
+			associateIndexWithCondition(lookup, condition, condition.IndexName())
 		}
 	}
 }
 
-func (d *Database) associateIndexWithCondition(condition Condition, indexName string) {
-	if index, exists := d.indexes[indexName]; exists {
+func associateIndexWithCondition(lookup map[string]indexes.Index, condition Condition, indexName string) {
+	if index, exists := lookup[indexName]; exists {
 		condition.On(index)
 	} else {
 		condition.On(EmptyIndex)
 	}
 }
 
-// Serialize type + values to be passed to the storage engine
-func serializeValue(t string, value interface{}) []byte {
-	serialized, err := json.Marshal(value)
+// Serialize type + values to be passed to the storage engine as
+// [marker][codec id][uvarint type length][type][encoded value]
+func serializeValue(codec Codec, t string, value interface{}) ([]byte, error) {
+	encoded, err := codec.Encode(value)
 	if err != nil {
-		panic(err)
+		return nil, err
+	}
+	return frameValue(codec, t, encoded), nil
+}
+
+// Prefixes an already encoded value with its codec and type
+func frameValue(codec Codec, t string, encoded []byte) []byte {
+	final := make([]byte, 2+binary.MaxVarintLen64+len(t)+len(encoded))
+	final[0] = VALUE_MARKER
+	final[1] = codec.Id()
+	n := 2 + binary.PutUvarint(final[2:], uint64(len(t)))
+	n += copy(final[n:], t)
+	n += copy(final[n:], encoded)
+	return final[:n]
+}
+
+// Splits a value read directly from the storage engine into its
+// type, codec and encoded document
+func DeserializeValue(data []byte) (string, Codec, []byte, error) {
+	return deserializeValue(data)
+}
+
+// Deserialize type + value from the storage engine. Values written before
+// codecs were introduced are JSON, optionally prefixed with type|
+func deserializeValue(data []byte) (string, Codec, []byte, error) {
+	if len(data) == 0 || data[0] != VALUE_MARKER {
+		return deserializeLegacyValue(data)
+	}
+	if len(data) < 2 {
+		return "", nil, nil, ErrCorruptValue
+	}
+	codec, exists := getCodec(data[1])
+	if exists == false {
+		return "", nil, nil, ErrUnknownCodec
 	}
-	if len(t) == 0 {
-		return serialized
+	length, n := binary.Uvarint(data[2:])
+	if n <= 0 || uint64(len(data)-2-n) < length {
+		return "", nil, nil, ErrCorruptValue
 	}
-	bt := []byte(t + "|")
-	final := make([]byte, len(serialized)+len(bt))
-	copy(final, bt)
-	copy(final[len(bt)+1:], serialized)
-	return final
+	start := 2 + n
+	end := start + int(length)
+	return string(data[start:end]), codec, data[end:], nil
 }
 
-// Deserialize type  + value from the storage engine
-func deserializeValue(data []byte) (string, []byte) {
-	index := bytes.Index(data[:32], []byte{'|'})
+func deserializeLegacyValue(data []byte) (string, Codec, []byte, error) {
+	head := data
+	if len(head) > 32 {
+		head = head[:32]
+	}
+	index := bytes.IndexByte(head, '|')
 	if index == -1 {
-		return "", data
+		return "", JSONCodec, data, nil
 	}
-	t := string(data[:index])
-	return t, data[index+1:]
+	return string(data[:index]), JSONCodec, data[index+1:], nil
 }
 
 // Serialize values to be passed to the storage engine
-func serializeSort(ids []key.Type, ranged bool) []byte {
-	sort := &SerializedSort{ids, ranged}
+func serializeSort(ids []key.Type, scores []int, ranged bool) []byte {
+	sort := &SerializedSort{ids, scores, ranged}
 	serialized, err := json.Marshal(sort)
 	if err != nil {
 		panic(err)
@@ -416,12 +980,75 @@ func serializeSort(ids []key.Type, ranged bool) []byte {
 }
 
 // Deserializes an indexes from the storage engine
-func deserializeSort(raw []byte) *SerializedSort {
+func deserializeSort(raw []byte) (*SerializedSort, error) {
 	sort := new(SerializedSort)
 	if err := json.Unmarshal(raw, sort); err != nil {
-		panic(err)
+		return nil, err
+	}
+	if sort.Ranged && len(sort.Ids) != len(sort.Scores) {
+		return nil, ErrCorruptValue
+	}
+	return sort, nil
+}
+
+func toKeys(ids []uint) []key.Type {
+	keys := make([]key.Type, len(ids))
+	for i, id := range ids {
+		keys[i] = key.Type(id)
 	}
-	return sort
+	return keys
+}
+
+// Adds ids to the start or end of existing, removing them from their
+// current position. When scores are given, the added ids are scored
+// to keep the order
+func extendIds(existing []key.Type, scores []int, ids []key.Type, prepend bool) ([]key.Type, []int) {
+	added := make(map[key.Type]struct{}, len(ids))
+	for _, id := range ids {
+		added[id] = struct{}{}
+	}
+	kept := make([]key.Type, 0, len(existing)+len(ids))
+	var keptScores []int
+	if scores != nil {
+		keptScores = make([]int, 0, len(existing)+len(ids))
+	}
+	if prepend {
+		kept = append(kept, ids...)
+	}
+	for i, id := range existing {
+		if _, exists := added[id]; exists == false {
+			kept = append(kept, id)
+			if scores != nil {
+				keptScores = append(keptScores, scores[i])
+			}
+		}
+	}
+	if prepend == false {
+		kept = append(kept, ids...)
+	}
+	if scores == nil {
+		return kept, nil
+	}
+
+	if prepend {
+		start := 0
+		if len(keptScores) > 0 {
+			start = keptScores[0] - len(ids)
+		}
+		newScores := make([]int, len(ids), len(kept))
+		for i := range ids {
+			newScores[i] = start + i
+		}
+		return kept, append(newScores, keptScores...)
+	}
+	start := 0
+	if len(keptScores) > 0 {
+		start = keptScores[len(keptScores)-1] + 1
+	}
+	for i := range ids {
+		keptScores = append(keptScores, start+i)
+	}
+	return kept, keptScores
 }
 
 func removeValue(values []string, target string) ([]string, bool) {
@@ -438,8 +1065,49 @@ func removeValue(values []string, target string) ([]string, bool) {
 }
 
 func (db *Database) safeDelete(indexName string, id key.Type) {
-	if index, exists := db.getIndex(indexName); exists {
+	if index, exists := db.getWritableIndex(indexName); exists {
 		index.Remove(id)
+		if set, ok := index.(*indexes.SetString); ok {
+			db.removeEmptySet(indexName, set)
+		}
+	}
+}
+
+// Adds the id to the set. The set is modified while the index lock is
+// held so that it can't be removed for being empty at the same time
+func (db *Database) addToSet(indexName string, id key.Type, getter func(string) indexes.Index) {
+	for {
+		index := getter(indexName)
+		db.indexLock.RLock()
+		current := db.indexes[indexName] == index
+		if current {
+			index.Set(id)
+		}
+		db.indexLock.RUnlock()
+		if current {
+			return
+		}
+	}
+}
+
+// Removes the set if it no longer has any member
+func (db *Database) removeEmptySet(indexName string, set *indexes.SetString) {
+	set.RLock()
+	empty := set.Len() == 0
+	set.RUnlock()
+	if empty == false {
+		return
+	}
+	db.indexLock.Lock()
+	defer db.indexLock.Unlock()
+	if db.indexes[indexName] != set {
+		return
+	}
+	set.RLock()
+	defer set.RUnlock()
+	if set.Len() == 0 {
+		delete(db.indexes, indexName)
+		delete(db.versions, indexName)
 	}
 }
 
@@ -468,7 +1136,7 @@ func (db *Database) getOrCreateBigSetStringIndex(indexName string) indexes.Index
 }
 
 func (db *Database) getOrCreateIndex(indexName string, factory func() indexes.Index) indexes.Index {
-	if index, exists := db.getIndex(indexName); exists {
+	if index, exists := db.getWritableIndex(indexName); exists {
 		return index
 	}
 	index := factory()
@@ -478,10 +1146,12 @@ func (db *Database) getOrCreateIndex(indexName string, factory func() indexes.In
 		return index
 	}
 	db.indexes[indexName] = index
+	db.versions[indexName] = db.generation
 	return index
 }
 
 type SerializedSort struct {
 	Ids    []key.Type
+	Scores []int
 	Ranged bool
 }
@@ -4,7 +4,11 @@ import (
 	"github.com/karlseguin/gspec"
 	"github.com/karlseguin/nabu/indexes"
 	"github.com/karlseguin/nabu/key"
+	"io/ioutil"
+	"os"
+	"path"
 	"testing"
+	"time"
 )
 
 // this is a very broad test, tsk tsk
@@ -135,6 +139,82 @@ func TestBulkLoadsAnExistingSortedSet(t *testing.T) {
 	spec.Expect(db.indexes["new"].Contains(db.idMap.get("c", false))).ToEqual(false)
 }
 
+func TestLoadsSortedInts(t *testing.T) {
+	db := SmallDB()
+	defer db.Close()
+	db.LoadSortedInts("new", []uint{3, 1, 2}, []int{5, 10, 15})
+	assertClosedResult(t, db.Query("new").Execute(), 3, 1, 2)
+	assertClosedResult(t, db.Query("new").Where(GT("new", 5)).Execute(), 1, 2)
+}
+
+func TestAppendsAndPrependsToSortedInts(t *testing.T) {
+	db := SmallDB()
+	defer db.Close()
+	db.LoadSortedInts("new", []uint{3, 1, 2}, []int{5, 10, 15})
+	db.AppendSort("new", []uint{4, 3})
+	db.PrependSort("new", []uint{6})
+	assertClosedResult(t, db.Query("new").Execute(), 6, 1, 2, 4, 3)
+	assertClosedResult(t, db.Query("new").Where(GT("new", 15)).Execute(), 4, 3)
+}
+
+func TestAppendsToAMissingSort(t *testing.T) {
+	db := SmallDB()
+	defer db.Close()
+	db.AppendSort("new", []uint{4, 3})
+	assertClosedResult(t, db.Query("new").Execute(), 4, 3)
+}
+
+func TestAppendsAndPrependsToSortedStrings(t *testing.T) {
+	db := SmallDB()
+	defer db.Close()
+	db.loadSortedStrings("new", []key.Type{3, 1, 2})
+	db.AppendSort("new", []uint{1})
+	db.PrependSort("new", []uint{4})
+	assertClosedResult(t, db.Query("new").Execute(), 4, 3, 2, 1)
+}
+
+func TestRestoresLoadedSorts(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "nabu")
+	defer os.RemoveAll(dir)
+	config := func() *Configuration {
+		return Configure().QueryPoolSize(1).ResultsPoolSize(1, 1).DbPath(path.Join(dir, "data.db"))
+	}
+	db := New(config())
+	db.LoadSortedInts("new", []uint{3, 1, 2}, []int{5, 10, 15})
+	db.AppendSort("new", []uint{3})
+	db.Close()
+
+	db = New(config())
+	defer db.Close()
+	assertClosedResult(t, db.Query("new").Where(GTE("new", 10)).Execute(), 1, 2, 3)
+}
+
+func TestIgnoresSortedIntsWithMismatchedScores(t *testing.T) {
+	spec := gspec.New(t)
+	db := SmallDB()
+	defer db.Close()
+	db.LoadSortedInts("new", []uint{3, 1, 2}, []int{5, 10})
+	_, exists := db.indexes["new"]
+	spec.Expect(exists).ToEqual(false)
+}
+
+func TestSkipsCorruptPersistedSorts(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "nabu")
+	defer os.RemoveAll(dir)
+	config := func() *Configuration {
+		return Configure().QueryPoolSize(1).ResultsPoolSize(1, 1).DbPath(path.Join(dir, "data.db"))
+	}
+	db := New(config())
+	db.LoadSortedInts("new", []uint{3, 1}, []int{5, 10})
+	db.storage.PutSort("corrupt", []byte(`{"Ids":[1,2],"Scores":[1],"Ranged":true}`))
+	db.Close()
+
+	db = New(config())
+	defer db.Close()
+	assertClosedResult(t, db.Query("new").Execute(), 3, 1)
+	assertClosedResult(t, db.Query("corrupt").Execute())
+}
+
 func TestContains(t *testing.T) {
 	spec := gspec.New(t)
 	db := SmallDB()
@@ -146,6 +226,93 @@ func TestContains(t *testing.T) {
 	spec.Expect(db.Contains("trending", 94)).ToEqual(true)
 }
 
+func TestExpiredDocumentsAreNotReturned(t *testing.T) {
+	spec := gspec.New(t)
+	db := SmallDB()
+	defer db.Close()
+	db.Update(NewExpiringDoc(94, time.Now().Add(-time.Second), map[string]int{"age": 2}))
+	db.Update(NewExpiringDoc(95, time.Now().Add(time.Hour), map[string]int{"age": 3}))
+	spec.Expect(db.Get(94)).ToBeNil()
+	spec.Expect(db.Get(95).(*ExpiringDoc).id).ToEqual(uint(95))
+	spec.Expect(db.indexes["age"].Contains(94)).ToEqual(true)
+}
+
+func TestRemovesExpiredDocuments(t *testing.T) {
+	spec := gspec.New(t)
+	db := SmallDB()
+	defer db.Close()
+	db.Update(NewExpiringDoc(94, time.Now().Add(-time.Second), map[string]int{"age": 2}))
+	db.Update(NewExpiringDoc(95, time.Now().Add(time.Hour), map[string]int{"age": 3}))
+	db.removeExpired()
+	spec.Expect(db.lookup(94)).ToBeNil()
+	spec.Expect(db.indexes["age"].Contains(94)).ToEqual(false)
+	spec.Expect(db.indexes["age"].Contains(95)).ToEqual(true)
+	spec.Expect(len(db.expiry.lookup)).ToEqual(1)
+}
+
+func TestExpiredDocumentsAreNotContained(t *testing.T) {
+	spec := gspec.New(t)
+	db := SmallDB()
+	defer db.Close()
+	db.Update(NewExpiringDoc(94, time.Now().Add(-time.Second), map[string]int{"age": 2}))
+	db.Update(NewExpiringDoc(95, time.Now().Add(time.Hour), map[string]int{"age": 3}))
+	spec.Expect(db.Contains("age", 94)).ToEqual(false)
+	spec.Expect(db.Contains("age", 95)).ToEqual(true)
+}
+
+func TestDoesNotRemoveARenewedDocument(t *testing.T) {
+	spec := gspec.New(t)
+	db := SmallDB()
+	defer db.Close()
+	db.Update(NewExpiringDoc(94, time.Now().Add(-time.Second), map[string]int{"age": 2}))
+	now := time.Now().UnixNano()
+	db.Update(NewExpiringDoc(94, time.Now().Add(time.Hour), map[string]int{"age": 2}))
+	db.removeIfExpired(94, now)
+	spec.Expect(db.Get(94).(*ExpiringDoc).id).ToEqual(uint(94))
+	spec.Expect(db.indexes["age"].Contains(94)).ToEqual(true)
+}
+
+func TestUpdateClearsAnExpiry(t *testing.T) {
+	spec := gspec.New(t)
+	db := SmallDB()
+	defer db.Close()
+	db.Update(NewExpiringDoc(94, time.Now().Add(-time.Second), map[string]int{"age": 2}))
+	db.Update(NewDoc(94, map[string]int{"age": 2}))
+	spec.Expect(db.Get(94).(*Doc).id).ToEqual(uint(94))
+	spec.Expect(len(db.expiry.lookup)).ToEqual(0)
+}
+
+func TestUpdateAcceptsOlderVersionsByDefault(t *testing.T) {
+	spec := gspec.New(t)
+	db := SmallDB()
+	defer db.Close()
+	spec.Expect(db.Update(NewVersionedDoc(1, 5, 5))).ToBeNil()
+	spec.Expect(db.Update(NewVersionedDoc(1, 4, 4))).ToBeNil()
+	spec.Expect(db.Get(1).(*VersionedDoc).version).ToEqual(uint64(4))
+}
+
+func TestUpdateRejectsStaleVersions(t *testing.T) {
+	spec := gspec.New(t)
+	db := New(SmallConfig().RejectStaleVersions())
+	defer db.Close()
+	spec.Expect(db.Update(NewVersionedDoc(1, 5, 5))).ToBeNil()
+	spec.Expect(db.Update(NewVersionedDoc(1, 4, 4))).ToEqual(ErrStaleVersion)
+	spec.Expect(db.Update(NewVersionedDoc(1, 5, 6))).ToBeNil()
+	spec.Expect(db.Get(1).(*VersionedDoc).version).ToEqual(uint64(5))
+	spec.Expect(db.Contains("age", 1)).ToEqual(true)
+}
+
+func TestCompareAndUpdate(t *testing.T) {
+	spec := gspec.New(t)
+	db := SmallDB()
+	defer db.Close()
+	spec.Expect(db.CompareAndUpdate(NewVersionedDoc(1, 1, 1), 1)).ToEqual(ErrStaleVersion)
+	spec.Expect(db.CompareAndUpdate(NewVersionedDoc(1, 1, 1), 0)).ToBeNil()
+	spec.Expect(db.CompareAndUpdate(NewVersionedDoc(1, 2, 2), 0)).ToEqual(ErrStaleVersion)
+	spec.Expect(db.CompareAndUpdate(NewVersionedDoc(1, 2, 2), 1)).ToBeNil()
+	spec.Expect(db.Get(1).(*VersionedDoc).version).ToEqual(uint64(2))
+}
+
 type Doc struct {
 	id      uint
 	indexes map[string]int
@@ -169,6 +336,40 @@ func (d *Doc) GetType() string {
 	return "doc"
 }
 
+type ExpiringDoc struct {
+	*Doc
+	expires time.Time
+}
+
+func NewExpiringDoc(id uint, expires time.Time, indexes map[string]int) *ExpiringDoc {
+	return &ExpiringDoc{
+		Doc:     NewDoc(id, indexes),
+		expires: expires,
+	}
+}
+
+func (d *ExpiringDoc) ReadMeta(meta *Meta) {
+	d.Doc.ReadMeta(meta)
+	meta.ExpiresAt(d.expires)
+}
+
+type VersionedDoc struct {
+	*Doc
+	version uint64
+}
+
+func NewVersionedDoc(id uint, version uint64, age int) *VersionedDoc {
+	return &VersionedDoc{
+		Doc:     NewDoc(id, map[string]int{"age": age}),
+		version: version,
+	}
+}
+
+func (d *VersionedDoc) ReadMeta(meta *Meta) {
+	d.Doc.ReadMeta(meta)
+	meta.Version(d.version)
+}
+
 type StringDoc struct {
 	id      string
 	indexes map[string]int
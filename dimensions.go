@@ -0,0 +1,69 @@
+package nabu
+
+import (
+	"github.com/karlseguin/nabu/indexes"
+	"github.com/karlseguin/nabu/key"
+	"sync"
+)
+
+// Maps documents to their value within each set dimension (the name given
+// to Meta.Set), allowing queries to be collapsed on a dimension
+type Dimensions struct {
+	sync.RWMutex
+	memory int
+	lookup map[string]map[key.Type]string
+}
+
+func newDimensions() *Dimensions {
+	return &Dimensions{
+		lookup: make(map[string]map[key.Type]string),
+	}
+}
+
+func (d *Dimensions) set(name string, id key.Type, value string) {
+	d.Lock()
+	defer d.Unlock()
+	values, exists := d.lookup[name]
+	if exists == false {
+		values = make(map[key.Type]string)
+		d.lookup[name] = values
+	}
+	if _, exists := values[id]; exists == false {
+		d.memory += dimensionMemory
+	}
+	values[id] = value
+}
+
+func (d *Dimensions) remove(name string, id key.Type) {
+	d.Lock()
+	defer d.Unlock()
+	values, exists := d.lookup[name]
+	if exists == false {
+		return
+	}
+	if _, exists := values[id]; exists {
+		d.memory -= dimensionMemory
+		delete(values, id)
+	}
+	if len(values) == 0 {
+		delete(d.lookup, name)
+	}
+}
+
+// The document's value within the dimension
+func (d *Dimensions) get(name string, id key.Type) (string, bool) {
+	d.RLock()
+	defer d.RUnlock()
+	value, exists := d.lookup[name][id]
+	return value, exists
+}
+
+// Approximate number of bytes used. Values share their memory with the
+// strings given to Meta.Set
+func (d *Dimensions) Memory() int {
+	d.RLock()
+	defer d.RUnlock()
+	return d.memory
+}
+
+const dimensionMemory = indexes.KEY_SIZE + indexes.STRING_HEADER_SIZE + indexes.MAP_ENTRY_OVERHEAD
@@ -2,17 +2,19 @@ package nabu
 
 import (
 	"github.com/karlseguin/nabu/key"
+	"log"
+	"time"
 )
 
 /*
 Any document stored in nabu must implement this interface:
 
-    func (t *Tree) ReadMeta(m *nabu.Meta) {
-      m.Id(key.Type(t.Id))
-      m.Index("tree:borough", t.Borough)
-      m.Index("tree:species", t.Species)
-      m.Sort("tree:age", t.Age)
-    }
+	func (t *Tree) ReadMeta(m *nabu.Meta) {
+	  m.Id(key.Type(t.Id))
+	  m.Index("tree:borough", t.Borough)
+	  m.Index("tree:species", t.Species)
+	  m.Sort("tree:age", t.Age)
+	}
 */
 type Document interface {
 	ReadMeta(meta *Meta)
@@ -25,11 +27,17 @@ type Meta struct {
 	database *Database
 	IsUpdate bool
 	t        string
+	expires  time.Time
+	version  uint64
+	err      error
 
 	sortedInts    map[string]int
 	sortedStrings map[string]string
 	setStrings    map[string]struct{}
 	bigSetStrings map[string]struct{}
+	dimensions    map[string]string
+	uniques       map[string]string
+	composites    map[string][]string
 }
 
 func newMeta(database *Database, isUpdate bool) *Meta {
@@ -38,6 +46,8 @@ func newMeta(database *Database, isUpdate bool) *Meta {
 		sortedStrings: make(map[string]string),
 		setStrings:    make(map[string]struct{}),
 		bigSetStrings: make(map[string]struct{}),
+		dimensions:    make(map[string]string),
+		uniques:       make(map[string]string),
 		database:      database,
 		IsUpdate:      isUpdate,
 	}
@@ -57,28 +67,87 @@ func (m *Meta) Type(t string) *Meta {
 
 // The document's Id
 func (m *Meta) StringId(stringId string) uint {
-	m.id = m.database.idMap.get(stringId, true)
+	if m.database != nil {
+		m.id = m.database.idMap.get(stringId, true)
+	}
 	m.stringId = stringId
 	return uint(m.id)
 }
 
+// The time at which the document expires. Expired documents are excluded
+// from queries and eventually removed from the database
+func (m *Meta) ExpiresAt(expires time.Time) *Meta {
+	m.expires = expires
+	return m
+}
+
+// The document's version. Used to reject stale updates
+func (m *Meta) Version(version uint64) *Meta {
+	m.version = version
+	return m
+}
+
+// A value, such as a slug or email, which identifies the document within
+// name. Documents can be retrieved by it, via Database.GetBy, and updates
+// which give another document's value fail with ErrUniqueConflict
+func (m *Meta) Unique(name, value string) *Meta {
+	m.uniques[name] = value
+	return m
+}
+
+// Whether the configured schema allows the index. Records the first
+// mismatch, which rejects the update. While restoring, mismatches are
+// logged and the document is loaded without the index instead, so that
+// documents persisted before the schema changed aren't lost
+func (m *Meta) check(name string, kind IndexKind) bool {
+	if m.database == nil || m.database.schema == nil {
+		return true
+	}
+	err := m.database.schema.check(name, kind)
+	if err == nil {
+		return true
+	}
+	if m.database.loading {
+		log.Println(err.Error() + ", restoring the document without it")
+	} else if m.err == nil {
+		m.err = err
+	}
+	return false
+}
+
 func (m *Meta) getId() (key.Type, string) {
 	return m.id, m.stringId
 }
 
 // Add an int-based index
 func (m *Meta) SortedInt(name string, score int) *Meta {
-	m.sortedInts[name] = score
+	if m.check(name, SortedIntKind) {
+		m.sortedInts[name] = score
+	}
 	return m
 }
 
 // Add an int-based index
 func (m *Meta) SortedString(name string, score string) *Meta {
-	m.sortedStrings[name] = score
+	if m.check(name, SortedStringKind) {
+		m.sortedStrings[name] = score
+	}
 	return m
 }
 
+// Add the document to the name=value set. When a document has multiple
+// values for the same name, it's grouped by the lowest (see DistinctBy)
 func (m *Meta) Set(name, value string, big bool) *Meta {
+	kind := SetKind
+	if big {
+		kind = BigSetKind
+	}
+	if m.check(name, kind) == false {
+		return m
+	}
+	if existing, exists := m.dimensions[name]; exists == false || value < existing {
+		m.dimensions[name] = value
+	}
 	name = name + "=" + value
 	if big {
 		m.bigSetStrings[name] = struct{}{}
@@ -2,7 +2,10 @@ package nabu
 
 var emptyQuery = new(EmptyQuery)
 
-type EmptyQuery struct{}
+// A query without results, such as one against a missing index
+type EmptyQuery struct {
+	err error
+}
 
 func (q *EmptyQuery) NoCache() Query {
 	return q
@@ -10,6 +13,12 @@ func (q *EmptyQuery) NoCache() Query {
 func (q *EmptyQuery) Set(index, value string) Query {
 	return q
 }
+func (q *EmptyQuery) NotSet(index, value string) Query {
+	return q
+}
+func (q *EmptyQuery) Boost(condition Condition, weight int) Query {
+	return q
+}
 func (q *EmptyQuery) Union(index string, values ...string) Query {
 	return q
 }
@@ -28,6 +37,31 @@ func (q *EmptyQuery) Offset(offset int) Query {
 func (q *EmptyQuery) IncludeTotal() Query {
 	return q
 }
+func (q *EmptyQuery) Project(indexNames ...string) Query {
+	return q
+}
 func (q *EmptyQuery) Execute() Result {
 	return EmptyResult
 }
+func (q *EmptyQuery) Iterate(fn func(id uint, doc Document) bool) {}
+func (q *EmptyQuery) Exact() Query {
+	return q
+}
+func (q *EmptyQuery) Count() int {
+	return 0
+}
+func (q *EmptyQuery) Sample(n int) Query {
+	return q
+}
+func (q *EmptyQuery) Shuffle(seed int64) Query {
+	return q
+}
+func (q *EmptyQuery) DistinctBy(name string, perValue int) Query {
+	return q
+}
+func (q *EmptyQuery) String() string {
+	return ""
+}
+func (q *EmptyQuery) Err() error {
+	return q.err
+}
@@ -0,0 +1,82 @@
+package nabu
+
+import (
+	"github.com/karlseguin/nabu/key"
+	"sync"
+	"time"
+)
+
+// Tracks when documents expire
+type Expiry struct {
+	sync.RWMutex
+	lookup map[key.Type]int64
+}
+
+func newExpiry() *Expiry {
+	return &Expiry{
+		lookup: make(map[key.Type]int64),
+	}
+}
+
+// Records the time at which the document expires
+func (e *Expiry) set(id key.Type, expires time.Time) {
+	e.Lock()
+	e.lookup[id] = expires.UnixNano()
+	e.Unlock()
+}
+
+// The time at which the document expires
+func (e *Expiry) get(id key.Type) (time.Time, bool) {
+	e.RLock()
+	defer e.RUnlock()
+	expires, exists := e.lookup[id]
+	if exists == false {
+		return time.Time{}, false
+	}
+	return time.Unix(0, expires), true
+}
+
+// Forgets about the document's expiry
+func (e *Expiry) remove(id key.Type) {
+	e.RLock()
+	_, exists := e.lookup[id]
+	e.RUnlock()
+	if exists == false {
+		return
+	}
+	e.Lock()
+	delete(e.lookup, id)
+	e.Unlock()
+}
+
+// The current time, or 0 if no document is set to expire.
+// Lets queries skip expiry checks entirely in the common case
+func (e *Expiry) now() int64 {
+	e.RLock()
+	defer e.RUnlock()
+	if len(e.lookup) == 0 {
+		return 0
+	}
+	return time.Now().UnixNano()
+}
+
+// Whether the document has expired as of now
+func (e *Expiry) expired(id key.Type, now int64) bool {
+	e.RLock()
+	expires, exists := e.lookup[id]
+	e.RUnlock()
+	return exists && expires <= now
+}
+
+// The ids of all documents which have expired as of now
+func (e *Expiry) expiredIds(now int64) []key.Type {
+	e.RLock()
+	defer e.RUnlock()
+	ids := make([]key.Type, 0)
+	for id, expires := range e.lookup {
+		if expires <= now {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
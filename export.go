@@ -0,0 +1,183 @@
+package nabu
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/karlseguin/nabu/indexes"
+	"github.com/karlseguin/nabu/key"
+	"io"
+	"sort"
+)
+
+// The number of imported documents written to storage per transaction
+const IMPORT_BATCH_SIZE = 1000
+
+// A line of an export: either a document or the content of a bulk
+// loaded sort index, of the database or of the namespace (nested
+// namespaces are given from the outermost in)
+type exportRecord struct {
+	Namespace []string   `json:"namespace,omitempty"`
+	Id        uint       `json:"id,omitempty"`
+	StringId  string     `json:"stringId,omitempty"`
+	Type      string     `json:"type,omitempty"`
+	Codec     byte       `json:"codec,omitempty"`
+	Data      []byte     `json:"data,omitempty"`
+	Sort      string     `json:"sort,omitempty"`
+	Ids       []key.Type `json:"ids,omitempty"`
+	Scores    []int      `json:"scores,omitempty"`
+	Ranked    bool       `json:"ranked,omitempty"`
+}
+
+// Writes every document, followed by the bulk loaded sort indexes and
+// then the namespaces, as one JSON object per line. Documents are
+// encoded with the configured Codec. The export is of a snapshot (one
+// per namespace, which is loaded if needed), so writes aren't blocked
+func (d *Database) Export(w io.Writer) error {
+	return d.export(json.NewEncoder(w), nil)
+}
+
+func (d *Database) export(encoder *json.Encoder, namespace []string) error {
+	snapshot := d.Snapshot()
+	defer snapshot.Close()
+
+	for _, id := range snapshot.ids() {
+		record, err := d.exportDocument(snapshot, id)
+		if err != nil {
+			return err
+		}
+		if record == nil {
+			continue
+		}
+		record.Namespace = namespace
+		if err := encoder.Encode(record); err != nil {
+			return err
+		}
+	}
+
+	for _, record := range d.exportSorts(snapshot) {
+		record.Namespace = namespace
+		if err := encoder.Encode(record); err != nil {
+			return err
+		}
+	}
+
+	for _, name := range d.namespaceNames() {
+		nested := append(namespace[:len(namespace):len(namespace)], name)
+		if err := d.Namespace(name).db.export(encoder, nested); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// The content of the bulk loaded sort indexes within the snapshot
+func (d *Database) exportSorts(snapshot *Snapshot) []*exportRecord {
+	d.indexLock.RLock()
+	names := make([]string, 0, len(d.statics))
+	for name := range d.statics {
+		names = append(names, name)
+	}
+	d.indexLock.RUnlock()
+	sort.Strings(names)
+
+	records := make([]*exportRecord, 0, len(names))
+	for _, name := range names {
+		record := &exportRecord{Sort: name}
+		switch index := snapshot.indexes[name].(type) {
+		case *indexes.SortedInts:
+			record.Ids, record.Scores = index.Ordered()
+			record.Ranked = true
+		case *indexes.SortedStrings:
+			record.Ids = index.Ids()
+		default:
+			continue
+		}
+		records = append(records, record)
+	}
+	return records
+}
+
+func (d *Database) exportDocument(snapshot *Snapshot, id key.Type) (*exportRecord, error) {
+	if now := d.expiry.now(); now != 0 && d.expiry.expired(id, now) {
+		return nil, nil
+	}
+	doc := snapshot.buckets[id.Bucket(d.bucketCount)][id]
+	if encoded, ok := doc.(*encodedDocument); ok {
+		t, codec, value, err := deserializeValue(encoded.data)
+		if err != nil {
+			return nil, err
+		}
+		return &exportRecord{Id: uint(id), StringId: encoded.stringId, Type: t, Codec: codec.Id(), Data: value}, nil
+	}
+	meta := newMeta(d, false)
+	doc.ReadMeta(meta)
+	data, err := d.codec.Encode(doc)
+	if err != nil {
+		return nil, err
+	}
+	return &exportRecord{Id: uint(id), StringId: meta.stringId, Type: meta.t, Codec: d.codec.Id(), Data: data}, nil
+}
+
+// Loads the documents and sort indexes written by Export, recreating
+// documents through the configured factory. String ids keep their
+// exported int ids, so imports are meant for empty databases. Storage
+// writes are batched into transactions of IMPORT_BATCH_SIZE documents
+func (d *Database) Import(r io.Reader) error {
+	if d.iFactory == nil && d.sFactory == nil {
+		return ErrNoFactory
+	}
+	d.storage.Begin()
+	defer d.storage.Commit()
+
+	decoder := json.NewDecoder(r)
+	for line, count := 1, 0; ; line++ {
+		record := new(exportRecord)
+		if err := decoder.Decode(record); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("nabu: import line %d: %v", line, err)
+		}
+		if err := d.importRecord(record); err != nil {
+			return fmt.Errorf("nabu: import line %d: %v", line, err)
+		}
+		if count++; count%IMPORT_BATCH_SIZE == 0 {
+			if err := d.storage.Commit(); err != nil {
+				return err
+			}
+			d.storage.Begin()
+		}
+	}
+}
+
+func (d *Database) importRecord(record *exportRecord) error {
+	if len(record.Namespace) != 0 {
+		namespace := d.Namespace(record.Namespace[0]).db
+		record.Namespace = record.Namespace[1:]
+		return namespace.importRecord(record)
+	}
+	if len(record.Sort) != 0 {
+		if record.Ranked && len(record.Scores) != len(record.Ids) {
+			return ErrCorruptValue
+		}
+		d.txnLock.Lock()
+		defer d.txnLock.Unlock()
+		if record.Ranked {
+			d.loadSortedInts(record.Sort, record.Ids, record.Scores)
+		} else {
+			d.loadSortedStrings(record.Sort, record.Ids)
+		}
+		return nil
+	}
+	codec, exists := getCodec(record.Codec)
+	if exists == false {
+		return ErrUnknownCodec
+	}
+	var doc Document
+	if d.iFactory != nil {
+		doc = d.iFactory(record.Id, record.Type, record.Data, codec)
+	} else {
+		d.idMap.set(record.StringId, key.Type(record.Id))
+		doc = d.sFactory(record.StringId, record.Id, record.Type, record.Data, codec)
+	}
+	return d.Update(doc)
+}
@@ -0,0 +1,130 @@
+package nabu
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/karlseguin/gspec"
+	"io/ioutil"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestExportsAndImportsIntIdDocuments(t *testing.T) {
+	spec := gspec.New(t)
+	source := New(SmallConfig().CodecIntFactory(productFactory))
+	defer source.Close()
+	for i := 1; i <= 3; i++ {
+		source.Update(&Product{Id: uint(i), Name: "p" + strconv.Itoa(i), Rank: 10 - i})
+	}
+	source.LoadSortedInts("trending", []uint{3, 1}, []int{1, 2})
+
+	buffer := new(bytes.Buffer)
+	spec.Expect(source.Export(buffer)).ToBeNil()
+	spec.Expect(strings.Count(buffer.String(), "\n")).ToEqual(4)
+
+	target := New(SmallConfig().CodecIntFactory(productFactory))
+	defer target.Close()
+	spec.Expect(target.Import(buffer)).ToBeNil()
+	spec.Expect(target.Get(2).(*Product).Name).ToEqual("p2")
+	assertIds(t, executeIds(target.Query("rank")), 3, 2, 1)
+	assertIds(t, executeIds(target.Query("trending")), 3, 1)
+}
+
+func TestExportsAndImportsStringIdDocuments(t *testing.T) {
+	spec := gspec.New(t)
+	source := New(SmallConfig().StringFactory(characterFactory))
+	defer source.Close()
+	for _, name := range []string{"leto", "paul", "ghanima"} {
+		source.Update(&Character{Id: name, Power: len(name)})
+	}
+	source.RemoveByStringId("paul")
+
+	buffer := new(bytes.Buffer)
+	spec.Expect(source.Export(buffer)).ToBeNil()
+	target := New(SmallConfig().StringFactory(characterFactory))
+	defer target.Close()
+	spec.Expect(target.Import(buffer)).ToBeNil()
+	spec.Expect(target.Get(3).(*Character).Id).ToEqual("ghanima")
+	spec.Expect(target.StringGet("leto").(*Character).Power).ToEqual(4)
+	spec.Expect(target.StringGet("paul")).ToBeNil()
+	target.Update(&Character{Id: "alia", Power: 4})
+	spec.Expect(target.Get(4).(*Character).Id).ToEqual("alia")
+}
+
+func TestImportsIntoStorage(t *testing.T) {
+	spec := gspec.New(t)
+	dir, _ := ioutil.TempDir("", "nabu")
+	defer os.RemoveAll(dir)
+	source := New(SmallConfig().CodecIntFactory(productFactory))
+	defer source.Close()
+	for i := 1; i <= IMPORT_BATCH_SIZE+5; i++ {
+		source.Update(&Product{Id: uint(i), Rank: i})
+	}
+	buffer := new(bytes.Buffer)
+	source.Export(buffer)
+
+	config := Configure().QueryPoolSize(1).ResultsPoolSize(1, 1).DbPath(path.Join(dir, "data.db")).CodecIntFactory(productFactory)
+	target := New(config)
+	spec.Expect(target.Import(buffer)).ToBeNil()
+	target.Close()
+
+	target = New(config)
+	defer target.Close()
+	spec.Expect(target.Query("rank").Exact().Count()).ToEqual(IMPORT_BATCH_SIZE + 5)
+}
+
+func TestImportReportsInvalidLines(t *testing.T) {
+	spec := gspec.New(t)
+	db := New(SmallConfig().CodecIntFactory(productFactory))
+	defer db.Close()
+	err := db.Import(strings.NewReader(`{"id":1,"codec":1,"data":"eyJJZCI6MX0="}` + "\n" + `{"id":2,"codec":99}`))
+	spec.Expect(err.Error()).ToEqual("nabu: import line 2: unknown codec")
+	spec.Expect(db.Get(1)).ToNotBeNil()
+
+	db = New(SmallConfig())
+	defer db.Close()
+	spec.Expect(db.Import(strings.NewReader(""))).ToEqual(ErrNoFactory)
+}
+
+type Character struct {
+	Id    string
+	Power int
+}
+
+func (c *Character) ReadMeta(m *Meta) {
+	m.StringId(c.Id)
+	m.Type("character").SortedInt("power", c.Power)
+}
+
+func characterFactory(stringId string, id uint, t string, data []byte) Document {
+	character := new(Character)
+	if err := json.Unmarshal(data, character); err != nil {
+		panic(err)
+	}
+	return character
+}
+
+func TestExportsAndImportsNamespaces(t *testing.T) {
+	spec := gspec.New(t)
+	source := New(SmallConfig().CodecIntFactory(productFactory))
+	defer source.Close()
+	source.Update(NewProduct(1, "root"))
+	source.Namespace("t42").Update(NewProduct(1, "tenant"))
+	source.Namespace("t42").LoadSortedInts("trending", []uint{1}, []int{1})
+	source.Namespace("eu").Update(NewProduct(2, "region"))
+
+	buffer := new(bytes.Buffer)
+	spec.Expect(source.Export(buffer)).ToBeNil()
+
+	target := New(SmallConfig().CodecIntFactory(productFactory))
+	defer target.Close()
+	spec.Expect(target.Import(buffer)).ToBeNil()
+	spec.Expect(target.Get(1).(*Product).Name).ToEqual("root")
+	spec.Expect(target.Namespace("t42").Get(1).(*Product).Name).ToEqual("tenant")
+	assertIds(t, executeIds(target.Namespace("t42").Query("trending")), 1)
+	spec.Expect(target.Namespace("eu").Get(2).(*Product).Name).ToEqual("region")
+	spec.Expect(target.Get(2)).ToBeNil()
+}
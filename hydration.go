@@ -0,0 +1,114 @@
+package nabu
+
+import (
+	"container/list"
+	"github.com/karlseguin/nabu/key"
+	"log"
+	"sync"
+)
+
+// A document held in its persisted form until it's needed. Only
+// stored in buckets when configured for LazyHydration
+type encodedDocument struct {
+	stringId string
+	data     []byte
+}
+
+// Never called, encoded documents are hydrated before being used
+func (e *encodedDocument) ReadMeta(m *Meta) {}
+
+func (e *encodedDocument) Size() int {
+	return len(e.data) + len(e.stringId)
+}
+
+// A bounded, least recently used, cache of hydrated documents
+type documentCache struct {
+	sync.Mutex
+	size   int
+	list   *list.List
+	lookup map[key.Type]*list.Element
+}
+
+type cachedDocument struct {
+	id      key.Type
+	encoded *encodedDocument
+	doc     Document
+}
+
+func newDocumentCache(size int) *documentCache {
+	return &documentCache{
+		size:   size,
+		list:   list.New(),
+		lookup: make(map[key.Type]*list.Element, size),
+	}
+}
+
+// Gets the document hydrated from encoded. A document hydrated from a
+// different encoding (such as one since replaced) is a miss, which keeps
+// snapshots from seeing newer documents
+func (c *documentCache) get(id key.Type, encoded *encodedDocument) Document {
+	c.Lock()
+	defer c.Unlock()
+	element, exists := c.lookup[id]
+	if exists == false {
+		return nil
+	}
+	cached := element.Value.(*cachedDocument)
+	if cached.encoded != encoded {
+		return nil
+	}
+	c.list.MoveToFront(element)
+	return cached.doc
+}
+
+func (c *documentCache) set(id key.Type, encoded *encodedDocument, doc Document) {
+	if c.size == 0 {
+		return
+	}
+	c.Lock()
+	defer c.Unlock()
+	if element, exists := c.lookup[id]; exists {
+		element.Value = &cachedDocument{id, encoded, doc}
+		c.list.MoveToFront(element)
+		return
+	}
+	c.lookup[id] = c.list.PushFront(&cachedDocument{id, encoded, doc})
+	if c.list.Len() > c.size {
+		oldest := c.list.Back()
+		c.list.Remove(oldest)
+		delete(c.lookup, oldest.Value.(*cachedDocument).id)
+	}
+}
+
+func (c *documentCache) remove(id key.Type) {
+	c.Lock()
+	defer c.Unlock()
+	if element, exists := c.lookup[id]; exists {
+		c.list.Remove(element)
+		delete(c.lookup, id)
+	}
+}
+
+// Decodes the document, via the configured factory, if it's held encoded
+func (d *Database) hydrate(id key.Type, doc Document) Document {
+	encoded, ok := doc.(*encodedDocument)
+	if ok == false {
+		return doc
+	}
+	if cached := d.cache.get(id, encoded); cached != nil {
+		return cached
+	}
+	t, codec, value, err := deserializeValue(encoded.data)
+	if err != nil {
+		log.Printf("nabu: could not hydrate document %d: %v", id, err)
+		return nil
+	}
+	var hydrated Document
+	if d.iFactory != nil {
+		hydrated = d.iFactory(uint(id), t, value, codec)
+	} else {
+		hydrated = d.sFactory(encoded.stringId, uint(id), t, value, codec)
+	}
+	d.cache.set(id, encoded, hydrated)
+	return hydrated
+}
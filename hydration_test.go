@@ -0,0 +1,66 @@
+package nabu
+
+import (
+	"github.com/karlseguin/gspec"
+	"testing"
+)
+
+func TestLazyHydrationKeepsDocumentsEncoded(t *testing.T) {
+	spec := gspec.New(t)
+	db, hydrations := LazyDB(0)
+	defer db.Close()
+	db.Update(&Product{Id: 1, Name: "first", Rank: 3})
+	_, encoded := db.getBucket(1).Lookup[1].(*encodedDocument)
+	spec.Expect(encoded).ToEqual(true)
+	spec.Expect(db.Get(1).(*Product).Name).ToEqual("first")
+	spec.Expect(db.Get(1).(*Product).Name).ToEqual("first")
+	spec.Expect(*hydrations).ToEqual(2)
+	spec.Expect(db.Get(2)).ToBeNil()
+}
+
+func TestLazyHydrationCachesRecentlyUsedDocuments(t *testing.T) {
+	spec := gspec.New(t)
+	db, hydrations := LazyDB(1)
+	defer db.Close()
+	db.Update(&Product{Id: 1, Name: "first"})
+	db.Update(&Product{Id: 2, Name: "second"})
+	spec.Expect(db.Get(2).(*Product).Name).ToEqual("second")
+	spec.Expect(*hydrations).ToEqual(0)
+	spec.Expect(db.Get(1).(*Product).Name).ToEqual("first")
+	spec.Expect(db.Get(1).(*Product).Name).ToEqual("first")
+	spec.Expect(*hydrations).ToEqual(1)
+	spec.Expect(db.Get(2).(*Product).Name).ToEqual("second")
+	spec.Expect(*hydrations).ToEqual(2)
+}
+
+func TestLazyHydrationUpdatesIndexes(t *testing.T) {
+	db, _ := LazyDB(0)
+	defer db.Close()
+	db.Update(&Product{Id: 1, Rank: 3})
+	db.Update(&Product{Id: 2, Rank: 2})
+	db.Update(&Product{Id: 1, Rank: 1})
+	assertClosedResult(t, db.Query("rank").Execute(), 1, 2)
+	db.RemoveById(1)
+	assertClosedResult(t, db.Query("rank").Execute(), 2)
+}
+
+func TestLazyHydrationSnapshotsSeeTheirVersion(t *testing.T) {
+	spec := gspec.New(t)
+	db, _ := LazyDB(10)
+	defer db.Close()
+	db.Update(&Product{Id: 1, Name: "old"})
+	s := db.Snapshot()
+	defer s.Close()
+	db.Update(&Product{Id: 1, Name: "new"})
+	spec.Expect(db.Get(1).(*Product).Name).ToEqual("new")
+	spec.Expect(s.Get(1).(*Product).Name).ToEqual("old")
+}
+
+func LazyDB(cacheSize int) (*Database, *int) {
+	hydrations := new(int)
+	factory := func(id uint, t string, data []byte, codec Codec) Document {
+		*hydrations++
+		return productFactory(id, t, data, codec)
+	}
+	return New(SmallConfig().LazyHydration(cacheSize).CodecIntFactory(factory)), hydrations
+}
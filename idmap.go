@@ -1,6 +1,7 @@
 package nabu
 
 import (
+	"github.com/karlseguin/nabu/indexes"
 	"github.com/karlseguin/nabu/key"
 	"hash/fnv"
 	"sync"
@@ -11,6 +12,7 @@ const IDMAP_BUCKET_COUNT = uint32(32)
 
 type IdMap struct {
 	counter uint64
+	memory  int64
 	lookup  map[uint32]*IdMapBucket
 }
 
@@ -39,6 +41,7 @@ func (m *IdMap) load(ids map[uint]string) {
 			max = id
 		}
 		m.getBucket(s).lookup[s] = key.Type(id)
+		m.memory += idMemory(s)
 	}
 	m.counter = uint64(max)
 }
@@ -66,14 +69,45 @@ func (m *IdMap) get(s string, create bool) key.Type {
 
 	id = key.Type(atomic.AddUint64(&m.counter, 1))
 	bucket.lookup[s] = id
+	atomic.AddInt64(&m.memory, idMemory(s))
 	return id
 }
 
+// Maps s to a specific id, such as one being imported
+func (m *IdMap) set(s string, id key.Type) {
+	bucket := m.getBucket(s)
+	bucket.Lock()
+	if _, exists := bucket.lookup[s]; exists == false {
+		atomic.AddInt64(&m.memory, idMemory(s))
+	}
+	bucket.lookup[s] = id
+	bucket.Unlock()
+	for {
+		counter := atomic.LoadUint64(&m.counter)
+		if counter >= uint64(id) || atomic.CompareAndSwapUint64(&m.counter, counter, uint64(id)) {
+			return
+		}
+	}
+}
+
 func (m *IdMap) remove(s string) {
 	bucket := m.getBucket(s)
 	bucket.Lock()
+	_, exists := bucket.lookup[s]
 	delete(bucket.lookup, s)
 	bucket.Unlock()
+	if exists {
+		atomic.AddInt64(&m.memory, -idMemory(s))
+	}
+}
+
+// Approximate number of bytes used by the mappings
+func (m *IdMap) Memory() int {
+	return int(atomic.LoadInt64(&m.memory))
+}
+
+func idMemory(s string) int64 {
+	return int64(len(s) + indexes.STRING_HEADER_SIZE + indexes.KEY_SIZE + indexes.MAP_ENTRY_OVERHEAD)
 }
 
 func (m *IdMap) getBucket(s string) *IdMapBucket {
@@ -38,6 +38,18 @@ func (e *Empty) Contains(id key.Type) bool {
 	return false
 }
 
+func (e *Empty) At(position int) key.Type {
+	return key.NULL
+}
+
+func (e *Empty) Clone(name string) Index {
+	return e
+}
+
+func (e *Empty) Memory() int {
+	return 0
+}
+
 func (e *Empty) RLock() {
 }
 
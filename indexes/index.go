@@ -8,6 +8,15 @@ import (
 const MAX = 9223372036854775807
 const MIN = -9223372036854775807
 
+// Approximate sizes, in bytes, used to estimate memory usage
+const (
+	KEY_SIZE           = 8
+	POINTER_SIZE       = 8
+	SLICE_HEADER_SIZE  = 24
+	STRING_HEADER_SIZE = 16
+	MAP_ENTRY_OVERHEAD = 16
+)
+
 // SetInt(id key.Type, score int)
 // SetString(id key.Type, score string)
 
@@ -17,6 +26,8 @@ type Index interface {
 	Set(id key.Type)
 	Remove(id key.Type)
 	Contains(id key.Type) bool
+	Clone(name string) Index
+	Memory() int
 	RLock()
 	RUnlock()
 }
@@ -33,6 +44,14 @@ type Ranked interface {
 	GetRank(score int, first bool) int
 }
 
+// An index whose ids can be accessed by their position
+type Positional interface {
+	Index
+	// The id at the position, or key.NULL when out of range.
+	// Assumes the index is already read-locked
+	At(position int) key.Type
+}
+
 type Iterates interface {
 	Forwards() Iterator
 	Backwards() Iterator
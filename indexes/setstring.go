@@ -66,6 +66,39 @@ func (s *SetString) Contains(id key.Type) bool {
 	return exists
 }
 
+// The id at the position, assumes the set is already read-locked
+func (s *SetString) At(position int) key.Type {
+	// consider the 2 padding values
+	if position < 0 || position > len(s.ids)-3 {
+		return key.NULL
+	}
+	return s.ids[position+1]
+}
+
+// Copies the set under the given name. The ids are shared since they
+// are never modified in place
+func (s *SetString) Clone(name string) Index {
+	s.modifyLock.Lock()
+	defer s.modifyLock.Unlock()
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	lookup := make(map[key.Type]struct{}, len(s.lookup))
+	for id, _ := range s.lookup {
+		lookup[id] = struct{}{}
+	}
+	return &SetString{
+		name:   name,
+		ids:    s.ids,
+		lookup: lookup,
+	}
+}
+
+// Approximate number of bytes used
+// Assumes the set is already read-locked
+func (s *SetString) Memory() int {
+	return cap(s.ids)*KEY_SIZE + len(s.lookup)*(KEY_SIZE+MAP_ENTRY_OVERHEAD)
+}
+
 func (s *SetString) RLock() {
 	s.lock.RLock()
 }
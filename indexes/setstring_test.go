@@ -76,3 +76,26 @@ func setLoad(set *SetString, ids ...int) {
 		set.Set(key.Type(id))
 	}
 }
+
+func TestSetCloneIsIndependent(t *testing.T) {
+	spec := gspec.New(t)
+	s := NewSetString("test")
+	setLoad(s, 1, 2, 3)
+	clone := s.Clone("test").(*SetString)
+	s.Remove(2)
+	s.Set(4)
+	spec.Expect(clone.Contains(2)).ToEqual(true)
+	spec.Expect(clone.Contains(4)).ToEqual(false)
+	assertIterator(t, clone.Forwards(), 1, 2, 3)
+	assertIterator(t, s.Forwards(), 1, 3, 4)
+}
+
+func TestSetAt(t *testing.T) {
+	spec := gspec.New(t)
+	s := NewSetString("test")
+	setLoad(s, 1, 2, 3)
+	spec.Expect(s.At(0)).ToEqual(key.Type(1))
+	spec.Expect(s.At(2)).ToEqual(key.Type(3))
+	spec.Expect(s.At(3)).ToEqual(key.NULL)
+	spec.Expect(s.At(-1)).ToEqual(key.NULL)
+}
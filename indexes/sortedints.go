@@ -5,6 +5,7 @@ import (
 	"github.com/karlseguin/nabu/key"
 	"math"
 	"math/rand"
+	"sort"
 	"strings"
 	"sync"
 )
@@ -88,10 +89,83 @@ func (s *SortedInts) draw() {
 	fmt.Println("")
 }
 
+// Loads the values, scoring each by its position
 func (s *SortedInts) Load(values []key.Type) {
-	for index, value := range values {
-		s.setInt(value, index)
+	scores := make([]int, len(values))
+	for index, _ := range values {
+		scores[index] = index
 	}
+	s.BulkLoad(values, scores)
+}
+
+// Replaces the content of the index. When the ids are already ordered by
+// score (and then by id), the skip list is built in linear time. Otherwise
+// they are sorted first. Only an id's first occurrence (in score order) is
+// kept
+func (s *SortedInts) BulkLoad(ids []key.Type, scores []int) {
+	items := &scoredIds{ids, scores}
+	if sort.IsSorted(items) == false {
+		ids = append([]key.Type(nil), ids...)
+		scores = append([]int(nil), scores...)
+		sort.Stable(&scoredIds{ids, scores})
+	}
+
+	list := NewSortedInts(s.name)
+	last := make([]*SortedIntsNode, maxLevel)
+	positions := make([]int, maxLevel)
+	for i := 0; i < maxLevel; i++ {
+		last[i] = list.head
+	}
+	prev := list.head
+	position := 0
+	for i, id := range ids {
+		if _, exists := list.lookup[id]; exists {
+			continue
+		}
+		position++
+		level := list.getLevel()
+		node := &SortedIntsNode{
+			id:    id,
+			prev:  prev,
+			score: scores[i],
+			width: make([]int, level+1),
+			next:  make([]*SortedIntsNode, level+1),
+		}
+		for l := 0; l <= level; l++ {
+			last[l].next[l] = node
+			node.width[l] = position - positions[l]
+			last[l] = node
+			positions[l] = position
+		}
+		list.lookup[id] = scores[i]
+		prev = node
+	}
+	for l := 0; l < maxLevel; l++ {
+		if last[l] != list.head {
+			last[l].next[l] = list.tail
+		}
+	}
+	list.tail.prev = prev
+
+	s.lock.Lock()
+	s.head = list.head
+	s.tail = list.tail
+	s.levels = list.levels
+	s.lookup = list.lookup
+	s.lock.Unlock()
+}
+
+// The ids and their scores, in order
+func (s *SortedInts) Ordered() ([]key.Type, []int) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	ids := make([]key.Type, 0, len(s.lookup))
+	scores := make([]int, 0, len(s.lookup))
+	for node := s.head.next[0]; node != s.tail; node = node.next[0] {
+		ids = append(ids, node.id)
+		scores = append(scores, node.score)
+	}
+	return ids, scores
 }
 
 func (s *SortedInts) Set(id key.Type) {
@@ -148,6 +222,58 @@ func (s *SortedInts) Score(id key.Type) (int, bool) {
 	return score, exists
 }
 
+// The id at the position (rank), found via the skip list's widths
+// Assumes the index is already read-locked
+func (s *SortedInts) At(position int) key.Type {
+	if position < 0 {
+		return key.NULL
+	}
+	return s.offset(position).id
+}
+
+// Copies the index under the given name, preserving the skip list's
+// structure
+func (s *SortedInts) Clone(name string) Index {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	clone := NewSortedInts(name)
+	clone.levels = s.levels
+	nodes := make(map[*SortedIntsNode]*SortedIntsNode, len(s.lookup)+2)
+	nodes[s.head] = clone.head
+	nodes[s.tail] = clone.tail
+	for node := s.head.next[0]; node != s.tail; node = node.next[0] {
+		nodes[node] = &SortedIntsNode{
+			id:    node.id,
+			score: node.score,
+			width: make([]int, len(node.width)),
+			next:  make([]*SortedIntsNode, len(node.next)),
+		}
+	}
+	for original, node := range nodes {
+		copy(node.width, original.width)
+		for i, next := range original.next {
+			if next != nil {
+				node.next[i] = nodes[next]
+			}
+		}
+		if original.prev != nil {
+			node.prev = nodes[original.prev]
+		}
+	}
+	for id, score := range s.lookup {
+		clone.lookup[id] = score
+	}
+	return clone
+}
+
+// Approximate number of bytes used. Nodes are assumed to have the
+// expected number of levels (2)
+// Assumes the index is already read-locked
+func (s *SortedInts) Memory() int {
+	node := 8 + KEY_SIZE + 2*SLICE_HEADER_SIZE + POINTER_SIZE + 2*(POINTER_SIZE+8)
+	return len(s.lookup) * (node + KEY_SIZE + 8 + MAP_ENTRY_OVERHEAD)
+}
+
 // Read locks the index
 func (s *SortedInts) RLock() {
 	s.lock.RLock()
@@ -227,15 +353,13 @@ func (s *SortedInts) remove(id key.Type) {
 					nn.width[i] += next.width[i] - 1
 				}
 				break
-			} else if next.score > score {
+			} else if next.score > score || (next.score == score && next.id > id) {
 				next.width[i] -= 1
 				break
 			}
 		}
 	}
-	if current.next[0] == s.tail {
-		s.tail.prev = current
-	}
+	current.next[0].prev = current
 	delete(s.lookup, id)
 }
 
@@ -372,6 +496,10 @@ func (i *SortedIntsForwardIterator) Offset(offset int) Iterator {
 	if offset > 0 {
 		i.node = i.list.offset(offset)
 	}
+	if i.node.score > i.to {
+		// nothing is within the range
+		i.node = i.list.tail
+	}
 	return i
 }
 
@@ -417,6 +545,10 @@ func (i *SortedIntsBackwardsIterator) Offset(offset int) Iterator {
 	} else if offset > 0 {
 		i.node = i.list.offset(i.list.Len() - offset - 1)
 	}
+	if i.node.score < i.from {
+		// nothing is within the range
+		i.node = i.list.head
+	}
 	return i
 }
 
@@ -431,3 +563,25 @@ func (i *SortedIntsBackwardsIterator) Range(from, to int) Iterator {
 func (i *SortedIntsBackwardsIterator) Close() {
 	i.list.lock.RUnlock()
 }
+
+// Sorts ids by score, then by id
+type scoredIds struct {
+	ids    []key.Type
+	scores []int
+}
+
+func (s *scoredIds) Len() int {
+	return len(s.ids)
+}
+
+func (s *scoredIds) Less(i, j int) bool {
+	if s.scores[i] == s.scores[j] {
+		return s.ids[i] < s.ids[j]
+	}
+	return s.scores[i] < s.scores[j]
+}
+
+func (s *scoredIds) Swap(i, j int) {
+	s.ids[i], s.ids[j] = s.ids[j], s.ids[i]
+	s.scores[i], s.scores[j] = s.scores[j], s.scores[i]
+}
@@ -14,6 +14,18 @@ func TestSortedIntsLength(t *testing.T) {
 	spec.Expect(s.Len()).ToEqual(3)
 }
 
+func TestSortedIntsAt(t *testing.T) {
+	spec := gspec.New(t)
+	s := NewSortedInts("test")
+	for i := 1; i <= 100; i++ {
+		s.SetInt(key.Type(i), 101-i)
+	}
+	spec.Expect(s.At(0)).ToEqual(key.Type(100))
+	spec.Expect(s.At(49)).ToEqual(key.Type(51))
+	spec.Expect(s.At(99)).ToEqual(key.Type(1))
+	spec.Expect(s.At(100)).ToEqual(key.NULL)
+}
+
 func TestSortedIntsForwardIteration(t *testing.T) {
 	s := NewSortedInts("test")
 	s.Load([]key.Type{1, 2, 3})
@@ -101,6 +113,17 @@ func TestSortedIntsForwardIterationWithRangeOutsideBounds(t *testing.T) {
 	}
 }
 
+func TestSortedIntsIterationOfAnEmptyRange(t *testing.T) {
+	s := NewSortedInts("test")
+	for i := 1; i <= 6; i++ {
+		s.SetInt(key.Type(i), i*10)
+	}
+	assertIterator(t, s.Forwards().Range(31, 39).Offset(0))
+	assertIterator(t, s.Backwards().Range(31, 39).Offset(0))
+	assertIterator(t, s.Forwards().Range(50, 20).Offset(0))
+	assertIterator(t, s.Backwards().Range(50, 20).Offset(0))
+}
+
 func TestSortedIntsBackwardIterationWithRange(t *testing.T) {
 	for i := 0; i < 100; i++ {
 		rand.Seed(int64(i))
@@ -220,3 +243,101 @@ func assertIterator(t *testing.T, iterator Iterator, ids ...key.Type) {
 		i++
 	}
 }
+
+func TestSortedIntsCloneIsIndependent(t *testing.T) {
+	spec := gspec.New(t)
+	s := NewSortedInts("test")
+	for i := 1; i < 50; i++ {
+		s.SetInt(key.Type(i), i)
+	}
+	clone := s.Clone("test").(*SortedInts)
+	s.SetInt(100, 100)
+	s.Remove(10)
+	clone.SetInt(200, 0)
+	spec.Expect(clone.Contains(10)).ToEqual(true)
+	spec.Expect(clone.Contains(100)).ToEqual(false)
+	spec.Expect(s.Contains(200)).ToEqual(false)
+	spec.Expect(clone.Len()).ToEqual(50)
+	spec.Expect(clone.offset(0).id).ToEqual(key.Type(200))
+	spec.Expect(clone.offset(10).id).ToEqual(key.Type(10))
+	spec.Expect(clone.GetRank(25, true)).ToEqual(25)
+}
+
+func TestSortedIntsBulkLoadMatchesIncrementalInserts(t *testing.T) {
+	spec := gspec.New(t)
+	for seed := 0; seed < 50; seed++ {
+		rand.Seed(int64(seed))
+		ids, scores := make([]key.Type, 200), make([]int, 200)
+		incremental := NewSortedInts("test")
+		for i := 0; i < 200; i++ {
+			ids[i], scores[i] = key.Type(i), i/3
+			incremental.SetInt(key.Type(i), i/3)
+		}
+		s := NewSortedInts("test")
+		s.BulkLoad(ids, scores)
+		spec.Expect(s.Len()).ToEqual(200)
+		for i := 0; i < 200; i += 7 {
+			spec.Expect(s.offset(i).id).ToEqual(incremental.offset(i).id)
+			spec.Expect(s.GetRank(i/3, true)).ToEqual(incremental.GetRank(i/3, true))
+			spec.Expect(s.GetRank(i/3, false)).ToEqual(incremental.GetRank(i/3, false))
+		}
+		s.SetInt(500, 10)
+		s.Remove(31)
+		incremental.SetInt(500, 10)
+		incremental.Remove(31)
+		for i := 0; i < 200; i += 3 {
+			spec.Expect(s.offset(i).id).ToEqual(incremental.offset(i).id)
+		}
+		assertIterator(t, s.Forwards().Range(10, 11).Offset(1), 32, 500, 33, 34, 35)
+		assertIterator(t, s.Backwards().Range(10, 11).Offset(1), 34, 33, 500, 32, 30)
+	}
+}
+
+func TestSortedIntsRemoveAmongEqualScores(t *testing.T) {
+	spec := gspec.New(t)
+	for seed := 0; seed < 20; seed++ {
+		rand.Seed(int64(seed))
+		s := NewSortedInts("test")
+		for i := 1; i <= 200; i++ {
+			s.SetInt(key.Type(i), i%2)
+		}
+		expected := make([]key.Type, 0, 200)
+		for _, score := range []int{0, 1} {
+			for i := 1; i <= 200; i++ {
+				if i%2 != score {
+					continue
+				}
+				if i%3 == 0 {
+					s.Remove(key.Type(i))
+				} else {
+					expected = append(expected, key.Type(i))
+				}
+			}
+		}
+		spec.Expect(s.Len()).ToEqual(len(expected))
+		for i, id := range expected {
+			spec.Expect(s.At(i)).ToEqual(id)
+		}
+		reversed := make([]key.Type, len(expected))
+		for i, id := range expected {
+			reversed[len(expected)-1-i] = id
+		}
+		assertIterator(t, s.Backwards(), reversed...)
+	}
+}
+
+func TestSortedIntsBulkLoadSortsUnorderedInput(t *testing.T) {
+	s := NewSortedInts("test")
+	s.BulkLoad([]key.Type{4, 2, 3, 1, 2}, []int{40, 20, 30, 10, 50})
+	assertIterator(t, s.Forwards(), 1, 2, 3, 4)
+}
+
+func TestSortedIntsOrdered(t *testing.T) {
+	spec := gspec.New(t)
+	s := NewSortedInts("test")
+	s.SetInt(3, 30)
+	s.SetInt(1, 10)
+	ids, scores := s.Ordered()
+	spec.Expect(ids).ToEqual([]key.Type{1, 3})
+	spec.Expect(scores).ToEqual([]int{10, 30})
+}
@@ -55,6 +55,17 @@ func (s *SortedStrings) BulkLoad(ids []key.Type) {
 	s.lock.Unlock()
 }
 
+// The ids, in order
+func (s *SortedStrings) Ids() []key.Type {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	ids := make([]key.Type, len(s.list)-2)
+	for i, l := 1, len(s.list)-1; i < l; i++ {
+		ids[i-1] = s.list[i].id
+	}
+	return ids
+}
+
 func (s *SortedStrings) Set(id key.Type) {
 	s.SetString(id, "")
 }
@@ -130,10 +141,56 @@ func (s *SortedStrings) Score(id key.Type) (int, bool) {
 	return 0, false
 }
 
+// The string the document is sorted by
+// Assumes the index is already read-locked
+func (s *SortedStrings) StringScore(id key.Type) (string, bool) {
+	if item, exists := s.lookup[id]; exists {
+		return item.score, true
+	}
+	return "", false
+}
+
+// The id at the position, assumes the index is already read-locked
+func (s *SortedStrings) At(position int) key.Type {
+	// consider the 2 padding values
+	if position < 0 || position > len(s.list)-3 {
+		return key.NULL
+	}
+	return s.list[position+1].id
+}
+
 func (s *SortedStrings) GetRank(score int, first bool) int {
 	return 0
 }
 
+// Copies the index under the given name. Items are copied since their
+// rank is modified in place
+func (s *SortedStrings) Clone(name string) Index {
+	s.writeLock.Lock()
+	defer s.writeLock.Unlock()
+	list := make([]*SortedItem, len(s.list))
+	lookup := make(map[key.Type]*SortedItem, len(s.lookup))
+	list[0] = NullSortedItem
+	list[len(list)-1] = NullSortedItem
+	for i, l := 1, len(s.list)-1; i < l; i++ {
+		item := *s.list[i]
+		list[i] = &item
+		lookup[item.id] = &item
+	}
+	return &SortedStrings{
+		name:   name,
+		list:   list,
+		lookup: lookup,
+	}
+}
+
+// Approximate number of bytes used, excluding the scores' content
+// Assumes the index is already read-locked
+func (s *SortedStrings) Memory() int {
+	item := 8 + KEY_SIZE + STRING_HEADER_SIZE
+	return len(s.list)*POINTER_SIZE + len(s.lookup)*(item+KEY_SIZE+POINTER_SIZE+MAP_ENTRY_OVERHEAD)
+}
+
 func (s *SortedStrings) RLock() {
 	s.lock.RLock()
 }
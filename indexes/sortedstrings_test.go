@@ -128,3 +128,16 @@ func assertSortedStringsIterator(t *testing.T, iterator Iterator, expectedInts .
 		copy(expected, expected[1:])
 	}
 }
+
+func TestSortedStringsCloneIsIndependent(t *testing.T) {
+	spec := gspec.New(t)
+	s := NewSortedStrings("test")
+	s.SetString(1, "b")
+	s.SetString(2, "c")
+	clone := s.Clone("test").(*SortedStrings)
+	s.SetString(3, "a")
+	score, _ := clone.Score(1)
+	spec.Expect(score).ToEqual(1)
+	spec.Expect(clone.Contains(3)).ToEqual(false)
+	assertIterator(t, clone.Forwards(), 1, 2)
+}
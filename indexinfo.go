@@ -0,0 +1,130 @@
+package nabu
+
+import (
+	"errors"
+	"github.com/karlseguin/nabu/indexes"
+	"sort"
+)
+
+// Describes an index
+type IndexInfo struct {
+	Name string
+	// "sorted int", "sorted string" or "set"
+	Type string
+	// The number of documents in the index
+	Len int
+	// Approximate number of bytes used
+	Memory int
+}
+
+// Describes every index, ordered by name
+func (d *Database) Indexes() []IndexInfo {
+	d.indexLock.RLock()
+	infos := make([]IndexInfo, 0, len(d.indexes))
+	for name, index := range d.indexes {
+		index.RLock()
+		infos = append(infos, IndexInfo{
+			Name:   name,
+			Type:   indexType(index),
+			Len:    index.Len(),
+			Memory: index.Memory(),
+		})
+		index.RUnlock()
+	}
+	d.indexLock.RUnlock()
+	sort.Sort(indexInfos(infos))
+	return infos
+}
+
+var (
+	// Returned by RenameIndex when the index doesn't exist
+	ErrIndexNotFound = errors.New("index not found")
+	// Returned by RenameIndex when the new name is taken
+	ErrIndexExists = errors.New("index already exists")
+	// Returned by RenameIndex for an index maintained from documents,
+	// which keep referencing it by its old name
+	ErrDocumentIndex = errors.New("index is maintained from documents")
+)
+
+// Removes the index, including its persisted bulk loaded content.
+// Returns false if the index doesn't exist. An index maintained from
+// documents is recreated by the next update which references it, and
+// holds only the documents updated since; it's meant for dropping the
+// indexes of values which documents no longer have
+func (d *Database) DropIndex(name string) bool {
+	d.txnLock.Lock()
+	defer d.txnLock.Unlock()
+	d.indexLock.Lock()
+	_, exists := d.indexes[name]
+	_, static := d.statics[name]
+	delete(d.indexes, name)
+	delete(d.versions, name)
+	delete(d.statics, name)
+	d.indexLock.Unlock()
+	if static && d.persist {
+		d.storage.RemoveSort(name)
+	}
+	return exists
+}
+
+// Renames a bulk loaded index. An index maintained from documents can't
+// be renamed, since the documents would keep updating it by its old name
+func (d *Database) RenameIndex(from, to string) error {
+	d.txnLock.Lock()
+	defer d.txnLock.Unlock()
+	d.indexLock.RLock()
+	index, exists := d.indexes[from]
+	_, static := d.statics[from]
+	_, taken := d.indexes[to]
+	d.indexLock.RUnlock()
+	if exists == false {
+		return ErrIndexNotFound
+	}
+	if static == false {
+		return ErrDocumentIndex
+	}
+	if taken {
+		return ErrIndexExists
+	}
+
+	// writers are blocked, so the copy can't miss any change
+	renamed := index.Clone(to)
+	d.indexLock.Lock()
+	delete(d.indexes, from)
+	delete(d.versions, from)
+	d.indexes[to] = renamed
+	d.versions[to] = d.generation
+	delete(d.statics, from)
+	d.statics[to] = struct{}{}
+	d.indexLock.Unlock()
+	if d.persist {
+		d.storage.RenameSort(from, to)
+	}
+	return nil
+}
+
+func indexType(index indexes.Index) string {
+	switch index.(type) {
+	case *indexes.SortedInts:
+		return "sorted int"
+	case *indexes.SortedStrings:
+		return "sorted string"
+	case *indexes.SetString:
+		return "set"
+	}
+	return "unknown"
+}
+
+type indexInfos []IndexInfo
+
+func (infos indexInfos) Len() int {
+	return len(infos)
+}
+
+func (infos indexInfos) Less(i, j int) bool {
+	return infos[i].Name < infos[j].Name
+}
+
+func (infos indexInfos) Swap(i, j int) {
+	infos[i], infos[j] = infos[j], infos[i]
+}
@@ -0,0 +1,111 @@
+package nabu
+
+import (
+	"github.com/karlseguin/gspec"
+	"testing"
+)
+
+func TestDescribesIndexes(t *testing.T) {
+	spec := gspec.New(t)
+	db := New(SmallConfig())
+	defer db.Close()
+	makeIndex(db, "created", 1, 2, 3)
+	makeSet(db, "tag=a", 1)
+	infos := db.Indexes()
+	spec.Expect(len(infos)).ToEqual(2)
+	spec.Expect(infos[0].Name).ToEqual("created")
+	spec.Expect(infos[0].Type).ToEqual("sorted int")
+	spec.Expect(infos[0].Len).ToEqual(3)
+	spec.Expect(infos[0].Memory > 0).ToEqual(true)
+	spec.Expect(infos[1].Name).ToEqual("tag=a")
+	spec.Expect(infos[1].Type).ToEqual("set")
+	spec.Expect(infos[1].Len).ToEqual(1)
+}
+
+func TestDropsAnIndex(t *testing.T) {
+	spec := gspec.New(t)
+	db := SmallDB()
+	defer db.Close()
+	db.LoadSortedInts("age", []uint{1}, []int{1})
+	spec.Expect(db.DropIndex("age")).ToEqual(true)
+	spec.Expect(db.DropIndex("age")).ToEqual(false)
+	spec.Expect(db.Contains("age", 1)).ToEqual(false)
+	_, exists := db.indexes["age"]
+	spec.Expect(exists).ToEqual(false)
+}
+
+func TestRenamesAnIndex(t *testing.T) {
+	spec := gspec.New(t)
+	db := New(SmallConfig())
+	defer db.Close()
+	db.LoadSortedInts("created", []uint{1, 2, 3}, []int{1, 2, 3})
+	spec.Expect(db.RenameIndex("created", "age")).ToBeNil()
+	spec.Expect(db.RenameIndex("created", "age")).ToEqual(ErrIndexNotFound)
+	spec.Expect(db.indexes["age"].Name()).ToEqual("age")
+	assertClosedResult(t, db.Query("age").Where(GT("age", 1)).Execute(), 2, 3)
+}
+
+func TestRenameDoesNotOverwriteAnIndex(t *testing.T) {
+	spec := gspec.New(t)
+	db := SmallDB()
+	defer db.Close()
+	db.LoadSortedInts("created", []uint{1}, []int{1})
+	spec.Expect(db.RenameIndex("created", "age")).ToEqual(ErrIndexExists)
+	spec.Expect(db.indexes["created"].Name()).ToEqual("created")
+}
+
+func TestDropsAnIndexMaintainedFromDocuments(t *testing.T) {
+	spec := gspec.New(t)
+	db := New(SmallConfig())
+	defer db.Close()
+	db.Update(NewSetDoc(1, "old"))
+	db.Update(NewSetDoc(1, "new"))
+	spec.Expect(db.DropIndex("tag=new")).ToEqual(true)
+	spec.Expect(db.Contains("tag=new", 1)).ToEqual(false)
+	db.Update(NewSetDoc(2, "new"))
+	spec.Expect(db.Contains("tag=new", 2)).ToEqual(true)
+	spec.Expect(db.Contains("tag=new", 1)).ToEqual(false)
+}
+
+func TestDoesNotRenameAnIndexMaintainedFromDocuments(t *testing.T) {
+	spec := gspec.New(t)
+	db := New(SmallConfig())
+	defer db.Close()
+	db.Update(NewDoc(1, map[string]int{"age": 20}))
+	spec.Expect(db.RenameIndex("age", "years")).ToEqual(ErrDocumentIndex)
+	_, exists := db.indexes["years"]
+	spec.Expect(exists).ToEqual(false)
+	assertIds(t, executeIds(db.Query("age")), 1)
+}
+
+func TestRemovesEmptySets(t *testing.T) {
+	spec := gspec.New(t)
+	db := SmallDB()
+	defer db.Close()
+	db.Update(NewSetDoc(1, "a", "b"))
+	db.Update(NewSetDoc(2, "a"))
+	db.Update(NewSetDoc(1, "c"))
+	_, exists := db.indexes["tag=b"]
+	spec.Expect(exists).ToEqual(false)
+	spec.Expect(db.Contains("tag=a", 2)).ToEqual(true)
+	db.RemoveById(2)
+	_, exists = db.indexes["tag=a"]
+	spec.Expect(exists).ToEqual(false)
+	spec.Expect(db.Contains("tag=c", 1)).ToEqual(true)
+}
+
+type SetDoc struct {
+	id   uint
+	tags []string
+}
+
+func NewSetDoc(id uint, tags ...string) *SetDoc {
+	return &SetDoc{id, tags}
+}
+
+func (d *SetDoc) ReadMeta(meta *Meta) {
+	meta.IntId(d.id)
+	for _, tag := range d.tags {
+		meta.Set("tag", tag, false)
+	}
+}
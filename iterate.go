@@ -0,0 +1,105 @@
+package nabu
+
+import (
+	"github.com/karlseguin/nabu/indexes"
+	"github.com/karlseguin/nabu/key"
+)
+
+// Streams every matching document, in sort order, to fn until fn returns
+// false. Unlike Execute, the number of documents isn't capped (Limit is
+// ignored). Read locks are only held while a chunk of the sort index is
+// scanned, so documents changed during the iteration may be missed or
+// seen twice. Iterate a Snapshot's query for a consistent view
+func (q *NormalQuery) Iterate(fn func(id uint, doc Document) bool) {
+	defer q.reset()
+	if q.err != nil {
+		return
+	}
+	q.now = q.db.expiry.now()
+	skip := q.offset
+	ids := make([]key.Type, 0, q.db.iterationChunkSize)
+	for position, done := 0, false; done == false; position += q.db.iterationChunkSize {
+		ids, done = q.scan(position, ids[:0])
+		for _, id := range ids {
+			if skip > 0 {
+				skip--
+				continue
+			}
+			doc := q.get(id)
+			if doc != nil && fn(uint(id), doc) == false {
+				return
+			}
+		}
+	}
+}
+
+// Collects the matching ids from the chunk of the sort index which
+// starts at position. Returns true once the sort index is exhausted
+func (q *NormalQuery) scan(position int, ids []key.Type) ([]key.Type, bool) {
+	if q.snapshot == nil {
+		q.db.txnLock.RLock()
+		defer q.db.txnLock.RUnlock()
+	}
+	q.useComposites()
+	conditionCount := q.conditionCount
+	if conditionCount > 0 {
+		q.prepareConditions()
+		defer q.conditions[:conditionCount].RUnlock()
+	}
+	chunkSize := q.db.iterationChunkSize
+
+	if q.dynamicSort != nil {
+		end := position + chunkSize
+		if end > len(q.dynamicSort) {
+			end = len(q.dynamicSort)
+		}
+		for _, id := range q.dynamicSort[position:end] {
+			if q.matches(key.Type(id)) {
+				ids = append(ids, key.Type(id))
+			}
+		}
+		return ids, end == len(q.dynamicSort)
+	}
+
+	var iterator indexes.Iterator
+	if q.desc {
+		iterator = q.sort.Backwards()
+	} else {
+		iterator = q.sort.Forwards()
+	}
+	if q.sortCondition != nil {
+		iterator.Range(q.sortCondition.Range())
+	}
+	iterator.Offset(position)
+	id := iterator.Current()
+	for visited := 0; id != key.NULL && visited < chunkSize; visited++ {
+		if q.matches(id) {
+			ids = append(ids, id)
+		}
+		id = iterator.Next()
+	}
+	iterator.Close()
+	return ids, id == key.NULL
+}
+
+// Whether the document is unexpired, satisfies every condition and
+// isn't collapsed by DistinctBy. Must be called once per document
+func (q *NormalQuery) matches(id key.Type) bool {
+	if q.expired(id) {
+		return false
+	}
+	for i := 0; i < q.conditionCount; i++ {
+		if q.conditions[i].Contains(id) == false {
+			return false
+		}
+	}
+	return q.distinct(id)
+}
+
+// Gets a document from the query's snapshot, or the database
+func (q *NormalQuery) get(id key.Type) Document {
+	if q.snapshot != nil {
+		return q.snapshot.Get(uint(id))
+	}
+	return q.db.get(id)
+}
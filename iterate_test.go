@@ -0,0 +1,93 @@
+package nabu
+
+import (
+	"github.com/karlseguin/gspec"
+	"testing"
+)
+
+func TestIterateStreamsAllMatchesAcrossChunks(t *testing.T) {
+	db := IterateDB(150)
+	defer db.Close()
+	ids := iterate(db.Query("created").Where(GT("age", 139)))
+	assertIds(t, ids, 140, 141, 142, 143, 144, 145, 146, 147, 148, 149, 150)
+	if all := iterate(db.Query("created")); len(all) != 150 {
+		t.Errorf("expected 150 ids, got %d", len(all))
+	}
+}
+
+func TestIterateDescendingWithARange(t *testing.T) {
+	db := IterateDB(20)
+	defer db.Close()
+	ids := iterate(db.Query("created").Where(LT("created", 9)).Desc().Offset(2))
+	assertIds(t, ids, 6, 5, 4, 3, 2, 1)
+}
+
+func TestIterateStopsWhenTheCallbackReturnsFalse(t *testing.T) {
+	spec := gspec.New(t)
+	db := IterateDB(20)
+	defer db.Close()
+	ids := make([]uint, 0)
+	db.Query("created").Iterate(func(id uint, doc Document) bool {
+		ids = append(ids, id)
+		return len(ids) < 4
+	})
+	assertIds(t, ids, 1, 2, 3, 4)
+	spec.Expect(len(db.queryPool)).ToEqual(1)
+}
+
+func TestIterateADynamicSort(t *testing.T) {
+	db := IterateDB(20)
+	defer db.Close()
+	ids := iterate(db.DynamicQuery([]uint{9, 3, 12, 5, 18, 1, 7}).Where(GT("age", 4)))
+	assertIds(t, ids, 9, 12, 5, 18, 7)
+}
+
+func TestIterateASnapshot(t *testing.T) {
+	db := IterateDB(10)
+	defer db.Close()
+	s := db.Snapshot()
+	defer s.Close()
+	db.RemoveById(4)
+	db.Update(NewDoc(11, map[string]int{"created": 11}))
+	assertIds(t, iterate(s.Query("created").Where(GT("created", 7))), 8, 9, 10)
+	assertIds(t, iterate(db.Query("created").Where(GT("created", 7))), 8, 9, 10, 11)
+}
+
+func TestIteratesWithAChunkSizeBelowOne(t *testing.T) {
+	db := New(SmallConfig().IterationChunkSize(0))
+	defer db.Close()
+	for i := 1; i <= 3; i++ {
+		db.Update(NewDoc(uint(i), map[string]int{"created": i}))
+	}
+	assertIds(t, iterate(db.Query("created")), 1, 2, 3)
+}
+
+func IterateDB(count int) *Database {
+	db := New(SmallConfig().IterationChunkSize(3))
+	for i := 1; i <= count; i++ {
+		db.Update(NewDoc(uint(i), map[string]int{"created": i, "age": i}))
+	}
+	return db
+}
+
+func iterate(query Query) []uint {
+	ids := make([]uint, 0)
+	query.Iterate(func(id uint, doc Document) bool {
+		ids = append(ids, id)
+		return true
+	})
+	return ids
+}
+
+func assertIds(t *testing.T, actual []uint, expected ...uint) {
+	if len(actual) != len(expected) {
+		t.Errorf("expected %v, got %v", expected, actual)
+		return
+	}
+	for i, id := range expected {
+		if actual[i] != id {
+			t.Errorf("expected %v, got %v", expected, actual)
+			return
+		}
+	}
+}
@@ -22,7 +22,13 @@ func (t Type) Bucket(count int) int {
 // Serializes a key for storage
 func (t Type) Serialize() BytesCloser {
 	buffer := serializationPool.Checkout()
-	binary.PutUvarint(buffer.Bytes(), uint64(t))
+	bytes := buffer.Bytes()
+	n := binary.PutUvarint(bytes, uint64(t))
+	// pooled buffers hold the previous key, which would otherwise
+	// make the same key serialize differently from one call to the next
+	for i := n; i < len(bytes); i++ {
+		bytes[i] = 0
+	}
 	return buffer
 }
 
@@ -27,3 +27,13 @@ func TestDeserializesAnint(t *testing.T) {
 	spec := gspec.New(t)
 	spec.Expect(Deserialize([]byte{185, 211, 2, 0, 0, 0, 0, 0, 0, 0})).ToEqual(uint(43449))
 }
+
+func TestSerializesTheSameKeyTheSameWay(t *testing.T) {
+	spec := gspec.New(t)
+	for i := 0; i < 300; i++ {
+		Type(43449).Serialize().Close()
+	}
+	buffer := Type(1).Serialize()
+	defer buffer.Close()
+	spec.ExpectBytes(buffer.Bytes()).ToEqual([]byte{1, 0, 0, 0, 0, 0, 0, 0, 0, 0})
+}
@@ -0,0 +1,107 @@
+package nabu
+
+import (
+	"errors"
+	"log"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// How long a measurement of the database's memory is reused for when
+// enforcing the MemoryLimit
+const MEMORY_MEASUREMENT_TTL = time.Second
+
+// Returned when an update is rejected because the database is over its
+// configured MemoryLimit
+var ErrMemoryLimit = errors.New("memory limit exceeded")
+
+// Approximate memory used by the database, in bytes. Documents are only
+// accounted for by their entry, unless they implement Sized
+type MemoryStats struct {
+	Total      int
+	IdMap      int
+	Dimensions int
+	Uniques    int
+	Buckets    map[int]int
+	Indexes    map[string]int
+	Namespaces map[string]int
+}
+
+type memoryMeter struct {
+	sync.Mutex
+	used      int
+	measured  time.Time
+	measuring bool
+}
+
+// Measures the approximate memory used by the database
+func (d *Database) MemoryStats() *MemoryStats {
+	stats := &MemoryStats{
+		IdMap:      d.idMap.Memory(),
+		Dimensions: d.dimensions.Memory(),
+		Uniques:    d.uniques.Memory(),
+		Buckets:    make(map[int]int, len(d.Buckets)),
+	}
+	stats.Total = stats.IdMap + stats.Dimensions + stats.Uniques
+
+	for i, bucket := range d.Buckets {
+		bucket.RLock()
+		stats.Buckets[i] = bucket.memory
+		bucket.RUnlock()
+		stats.Total += stats.Buckets[i]
+	}
+
+	d.indexLock.RLock()
+	stats.Indexes = make(map[string]int, len(d.indexes))
+	for name, index := range d.indexes {
+		index.RLock()
+		stats.Indexes[name] = index.Memory()
+		index.RUnlock()
+		stats.Total += stats.Indexes[name]
+	}
+	d.indexLock.RUnlock()
+
+	namespaces := d.openNamespaces()
+	stats.Namespaces = make(map[string]int, len(namespaces))
+	for _, namespace := range namespaces {
+		stats.Namespaces[namespace.name] = namespace.db.MemoryStats().Total
+		stats.Total += stats.Namespaces[namespace.name]
+	}
+	return stats
+}
+
+// Checks the last measurement against the configured MemoryLimit
+func (d *Database) checkMemory() error {
+	if d.memoryLimit == 0 {
+		return nil
+	}
+	if d.parent != nil {
+		// the limit applies to the database as a whole
+		return d.parent.checkMemory()
+	}
+	meter := d.memory
+	meter.Lock()
+	stale := meter.measuring == false && time.Since(meter.measured) > MEMORY_MEASUREMENT_TTL
+	if stale {
+		meter.measuring = true
+	}
+	used := meter.used
+	meter.Unlock()
+
+	if stale {
+		// measured outside of the lock, other writers check against the
+		// last measurement in the meantime
+		used = d.MemoryStats().Total
+		meter.Lock()
+		meter.used, meter.measured, meter.measuring = used, time.Now(), false
+		meter.Unlock()
+		if used > d.memoryLimit && d.rejectOverMemoryLimit == false {
+			log.Println("nabu memory limit exceeded, using ~" + strconv.Itoa(used) + " bytes")
+		}
+	}
+	if used > d.memoryLimit && d.rejectOverMemoryLimit {
+		return ErrMemoryLimit
+	}
+	return nil
+}
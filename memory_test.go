@@ -0,0 +1,103 @@
+package nabu
+
+import (
+	"github.com/karlseguin/gspec"
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+	"time"
+)
+
+func TestMeasuresMemory(t *testing.T) {
+	spec := gspec.New(t)
+	db := SmallDB()
+	defer db.Close()
+	empty := db.MemoryStats()
+	db.Update(NewDoc(1, map[string]int{"age": 1}))
+	db.Update(NewStringDoc("a", map[string]int{"age": 2}))
+	stats := db.MemoryStats()
+	spec.Expect(stats.Total > empty.Total).ToEqual(true)
+	spec.Expect(stats.Indexes["age"] > empty.Indexes["age"]).ToEqual(true)
+	spec.Expect(stats.IdMap > 0).ToEqual(true)
+	spec.Expect(stats.Buckets[1] > 0).ToEqual(true)
+
+	db.RemoveById(1)
+	db.RemoveByStringId("a")
+	stats = db.MemoryStats()
+	spec.Expect(stats.Total).ToEqual(empty.Total)
+}
+
+func TestIncludesTheSizeOfSizedDocuments(t *testing.T) {
+	spec := gspec.New(t)
+	db := SmallDB()
+	defer db.Close()
+	db.Update(NewDoc(1, nil))
+	plain := db.MemoryStats().Buckets[1]
+	db.Update(&SizedDoc{NewDoc(1, nil), 1000})
+	spec.Expect(db.MemoryStats().Buckets[1]).ToEqual(plain + 1000)
+}
+
+func TestRejectsUpdatesOverTheMemoryLimit(t *testing.T) {
+	spec := gspec.New(t)
+	db := New(SmallConfig().MemoryLimit(1500, true))
+	defer db.Close()
+	spec.Expect(db.Update(&SizedDoc{NewDoc(1, nil), 1000})).ToBeNil()
+	db.memory.measured = time.Time{}
+	spec.Expect(db.Update(&SizedDoc{NewDoc(2, nil), 1000})).ToBeNil()
+	db.memory.measured = time.Time{}
+	spec.Expect(db.Update(NewDoc(3, nil))).ToEqual(ErrMemoryLimit)
+	spec.Expect(db.Get(3)).ToBeNil()
+}
+
+func TestChecksTheLastMeasurementWhileMeasuring(t *testing.T) {
+	spec := gspec.New(t)
+	db := New(SmallConfig().MemoryLimit(1500, true))
+	defer db.Close()
+	db.memory.used, db.memory.measuring = 2000, true
+	spec.Expect(db.Update(NewDoc(1, nil))).ToEqual(ErrMemoryLimit)
+	spec.Expect(db.memory.measured.IsZero()).ToEqual(true)
+	db.memory.measuring = false
+	spec.Expect(db.Update(NewDoc(1, nil))).ToBeNil()
+	spec.Expect(db.memory.measured.IsZero()).ToEqual(false)
+}
+
+func TestRestoresDocumentsOverTheMemoryLimit(t *testing.T) {
+	spec := gspec.New(t)
+	dir, _ := ioutil.TempDir("", "nabu")
+	defer os.RemoveAll(dir)
+	config := func() *Configuration {
+		return Configure().QueryPoolSize(1).ResultsPoolSize(1, 1).DbPath(path.Join(dir, "data.db")).CodecIntFactory(productFactory)
+	}
+	db := New(config())
+	for i := uint(1); i <= 3; i++ {
+		db.Update(NewProduct(i, "product"))
+	}
+	db.LoadSortedInts("popular", []uint{3, 1, 2}, []int{1, 2, 3})
+	db.Close()
+
+	db = New(config().MemoryLimit(1, true))
+	defer db.Close()
+	for i := uint(1); i <= 3; i++ {
+		spec.Expect(db.Get(i)).ToNotBeNil()
+	}
+}
+
+func TestLogsUpdatesOverTheMemoryLimit(t *testing.T) {
+	spec := gspec.New(t)
+	db := New(SmallConfig().MemoryLimit(10, false))
+	defer db.Close()
+	spec.Expect(db.Update(&SizedDoc{NewDoc(1, nil), 1000})).ToBeNil()
+	db.memory.measured = time.Time{}
+	spec.Expect(db.Update(NewDoc(2, nil))).ToBeNil()
+	spec.Expect(db.Get(2)).ToNotBeNil()
+}
+
+type SizedDoc struct {
+	*Doc
+	size int
+}
+
+func (d *SizedDoc) Size() int {
+	return d.size
+}
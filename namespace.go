@@ -0,0 +1,260 @@
+package nabu
+
+import (
+	"github.com/karlseguin/nabu/key"
+	"github.com/karlseguin/nabu/storage"
+	"sort"
+	"sync"
+)
+
+// An isolated database within a Database, with its own documents, ids,
+// indexes and id map. Namespaces share their parent's configuration,
+// query and result pools and storage. Only the per-namespace API is
+// exposed: backups, exports, transactions, nested namespaces and closing
+// belong to the parent
+type Namespace struct {
+	db     *Database
+	name   string
+	loaded sync.Once
+}
+
+// Describes a namespace
+type NamespaceInfo struct {
+	Name string
+	// The number of documents in the namespace
+	Documents int
+	// The number of indexes in the namespace
+	Indexes int
+	// Approximate number of bytes used
+	Memory int
+}
+
+// Gets the named namespace, creating it if needed. A namespace's
+// persisted documents are loaded the first time it's requested
+func (d *Database) Namespace(name string) *Namespace {
+	d.namespaceLock.Lock()
+	namespace, exists := d.namespaces[name]
+	if exists == false {
+		db := newDatabase(d.Configuration)
+		db.parent = d
+		db.queryPool = d.queryPool
+		db.sortedResults = d.sortedResults
+		db.unsortedResults = d.unsortedResults
+		db.storage = d.storage.Namespace(name)
+		namespace = &Namespace{db: db, name: name}
+		d.namespaces[name] = namespace
+	}
+	d.namespaceLock.Unlock()
+
+	// loaded outside of the lock, so that loading one namespace doesn't
+	// block requests for the others
+	namespace.loaded.Do(func() {
+		if d.skipLoad == false {
+			namespace.db.restore()
+		}
+		if d.expiryInterval > 0 {
+			go namespace.db.reap()
+		}
+	})
+	return namespace
+}
+
+// The namespace's name
+func (n *Namespace) Name() string {
+	return n.name
+}
+
+// See Database.Query
+func (n *Namespace) Query(indexName string) Query {
+	return n.db.Query(indexName)
+}
+
+// See Database.DynamicQuery
+func (n *Namespace) DynamicQuery(ids []uint) Query {
+	return n.db.DynamicQuery(ids)
+}
+
+// See Database.Snapshot
+func (n *Namespace) Snapshot() *Snapshot {
+	return n.db.Snapshot()
+}
+
+// See Database.Contains
+func (n *Namespace) Contains(indexName string, id uint) bool {
+	return n.db.Contains(indexName, id)
+}
+
+// See Database.StringContains
+func (n *Namespace) StringContains(indexName string, id string) bool {
+	return n.db.StringContains(indexName, id)
+}
+
+// See Database.KeyContains
+func (n *Namespace) KeyContains(indexName string, id key.Type) bool {
+	return n.db.KeyContains(indexName, id)
+}
+
+// See Database.Get
+func (n *Namespace) Get(id uint) Document {
+	return n.db.Get(id)
+}
+
+// See Database.StringGet
+func (n *Namespace) StringGet(id string) Document {
+	return n.db.StringGet(id)
+}
+
+// See Database.StringGets
+func (n *Namespace) StringGets(ids []string) []Document {
+	return n.db.StringGets(ids)
+}
+
+// See Database.GetBy
+func (n *Namespace) GetBy(name, value string) Document {
+	return n.db.GetBy(name, value)
+}
+
+// See Database.Update
+func (n *Namespace) Update(doc Document) error {
+	return n.db.Update(doc)
+}
+
+// See Database.CompareAndUpdate
+func (n *Namespace) CompareAndUpdate(doc Document, expectedVersion uint64) error {
+	return n.db.CompareAndUpdate(doc, expectedVersion)
+}
+
+// See Database.Remove
+func (n *Namespace) Remove(doc Document) {
+	n.db.Remove(doc)
+}
+
+// See Database.RemoveById
+func (n *Namespace) RemoveById(id uint) {
+	n.db.RemoveById(id)
+}
+
+// See Database.RemoveByStringId
+func (n *Namespace) RemoveByStringId(id string) {
+	n.db.RemoveByStringId(id)
+}
+
+// See Database.BulkLoadSortedString
+func (n *Namespace) BulkLoadSortedString(name string, ids []string) {
+	n.db.BulkLoadSortedString(name, ids)
+}
+
+// See Database.LoadSortedInts
+func (n *Namespace) LoadSortedInts(name string, ids []uint, scores []int) {
+	n.db.LoadSortedInts(name, ids, scores)
+}
+
+// See Database.AppendSort
+func (n *Namespace) AppendSort(name string, ids []uint) {
+	n.db.AppendSort(name, ids)
+}
+
+// See Database.PrependSort
+func (n *Namespace) PrependSort(name string, ids []uint) {
+	n.db.PrependSort(name, ids)
+}
+
+// See Database.Indexes
+func (n *Namespace) Indexes() []IndexInfo {
+	return n.db.Indexes()
+}
+
+// See Database.DropIndex
+func (n *Namespace) DropIndex(name string) bool {
+	return n.db.DropIndex(name)
+}
+
+// See Database.RenameIndex
+func (n *Namespace) RenameIndex(from, to string) error {
+	return n.db.RenameIndex(from, to)
+}
+
+// See Database.MemoryStats
+func (n *Namespace) MemoryStats() *MemoryStats {
+	return n.db.MemoryStats()
+}
+
+// Removes the namespace, including its persisted documents. The removed
+// Namespace must no longer be used
+func (d *Database) DropNamespace(name string) {
+	d.namespaceLock.Lock()
+	namespace, exists := d.namespaces[name]
+	delete(d.namespaces, name)
+	d.namespaceLock.Unlock()
+	if exists {
+		// the namespace's storage is a view of the parent's, which
+		// stays open
+		namespace.db.shutdown()
+	}
+	if d.persist {
+		d.storage.DropNamespace(name)
+	}
+}
+
+// Describes each open namespace, ordered by name
+func (d *Database) Namespaces() []NamespaceInfo {
+	namespaces := d.openNamespaces()
+	infos := make([]NamespaceInfo, len(namespaces))
+	for i, namespace := range namespaces {
+		documents := 0
+		for _, bucket := range namespace.db.Buckets {
+			bucket.RLock()
+			documents += len(bucket.Lookup)
+			bucket.RUnlock()
+		}
+		namespace.db.indexLock.RLock()
+		indexCount := len(namespace.db.indexes)
+		namespace.db.indexLock.RUnlock()
+		infos[i] = NamespaceInfo{
+			Name:      namespace.name,
+			Documents: documents,
+			Indexes:   indexCount,
+			Memory:    namespace.MemoryStats().Total,
+		}
+	}
+	return infos
+}
+
+// Loads every stored namespace, since they can't be loaded once the
+// storage is closed, and detaches them from it
+func (d *Database) restoreNamespaces() {
+	for _, name := range d.storage.Namespaces() {
+		namespace := d.Namespace(name).db
+		namespace.restoreNamespaces()
+		namespace.storage = storage.NullStorage
+	}
+}
+
+// The names of the open and the stored namespaces, ordered
+func (d *Database) namespaceNames() []string {
+	names := d.storage.Namespaces()
+	for _, namespace := range d.openNamespaces() {
+		names = append(names, namespace.name)
+	}
+	sort.Strings(names)
+	unique := names[:0]
+	for i, name := range names {
+		if i == 0 || name != names[i-1] {
+			unique = append(unique, name)
+		}
+	}
+	return unique
+}
+
+func (d *Database) openNamespaces() []*Namespace {
+	d.namespaceLock.Lock()
+	defer d.namespaceLock.Unlock()
+	namespaces := make([]*Namespace, 0, len(d.namespaces))
+	for _, namespace := range d.namespaces {
+		namespaces = append(namespaces, namespace)
+	}
+	sort.Slice(namespaces, func(i, j int) bool {
+		return namespaces[i].name < namespaces[j].name
+	})
+	return namespaces
+}
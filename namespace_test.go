@@ -0,0 +1,84 @@
+package nabu
+
+import (
+	"github.com/karlseguin/gspec"
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+)
+
+func TestNamespacesAreIsolated(t *testing.T) {
+	spec := gspec.New(t)
+	db := New(SmallConfig())
+	defer db.Close()
+	a, b := db.Namespace("a"), db.Namespace("b")
+	spec.Expect(db.Namespace("a")).ToEqual(a)
+	db.Update(NewDoc(1, map[string]int{"age": 1}))
+	a.Update(NewDoc(1, map[string]int{"age": 10}))
+	a.Update(NewDoc(2, map[string]int{"age": 5}))
+	b.Update(NewDoc(1, map[string]int{"power": 20}))
+
+	assertIds(t, executeIds(db.Query("age")), 1)
+	assertIds(t, executeIds(a.Query("age")), 2, 1)
+	spec.Expect(b.Query("age")).ToEqual(emptyQuery)
+	spec.Expect(db.Query("power")).ToEqual(emptyQuery)
+
+	result := a.Query("age").Limit(1).Execute()
+	spec.Expect(result.Documents()[0]).ToEqual(a.Get(2))
+	result.Close()
+
+	c, d := db.Namespace("c"), db.Namespace("d")
+	c.Update(NewStringDoc("leto", nil))
+	d.Update(NewStringDoc("paul", nil))
+	spec.Expect(c.StringGet("paul")).ToBeNil()
+	spec.Expect(c.Get(1).(*StringDoc).id).ToEqual("leto")
+	spec.Expect(d.Get(1).(*StringDoc).id).ToEqual("paul")
+}
+
+func TestNamespacesCanBeDescribedAndDropped(t *testing.T) {
+	spec := gspec.New(t)
+	db := New(SmallConfig())
+	defer db.Close()
+	db.Namespace("b").Update(NewDoc(1, map[string]int{"age": 10}))
+	db.Namespace("a").Update(NewDoc(1, map[string]int{"age": 10, "power": 3}))
+	db.Namespace("a").Update(NewDoc(2, map[string]int{"age": 10}))
+
+	infos := db.Namespaces()
+	spec.Expect(len(infos)).ToEqual(2)
+	spec.Expect(infos[0].Name, infos[0].Documents, infos[0].Indexes).ToEqual("a", 2, 2)
+	spec.Expect(infos[1].Name, infos[1].Documents, infos[1].Indexes).ToEqual("b", 1, 1)
+	spec.Expect(db.MemoryStats().Namespaces["a"]).ToEqual(infos[0].Memory)
+
+	db.DropNamespace("a")
+	spec.Expect(len(db.Namespaces())).ToEqual(1)
+	spec.Expect(db.Namespace("a").Get(1)).ToBeNil()
+}
+
+func TestNamespacesArePersisted(t *testing.T) {
+	spec := gspec.New(t)
+	dir, _ := ioutil.TempDir("", "nabu")
+	defer os.RemoveAll(dir)
+	config := func() *Configuration {
+		return Configure().QueryPoolSize(1).ResultsPoolSize(1, 1).DbPath(path.Join(dir, "data.db")).CodecIntFactory(productFactory)
+	}
+	db := New(config())
+	db.Update(NewProduct(1, "root"))
+	db.Namespace("t42").Update(NewProduct(1, "tenant"))
+	db.Namespace("t42").LoadSortedInts("trending", []uint{1}, []int{1})
+	db.Close()
+
+	db = New(config())
+	spec.Expect(db.Get(1).(*Product).Name).ToEqual("root")
+	spec.Expect(db.Namespace("t42").Get(1).(*Product).Name).ToEqual("tenant")
+	assertIds(t, executeIds(db.Namespace("t42").Query("trending")), 1)
+	db.DropNamespace("t42")
+	db.Update(NewProduct(2, "after"))
+	db.Close()
+
+	db = New(config())
+	defer db.Close()
+	spec.Expect(db.Namespace("t42").Get(1)).ToBeNil()
+	spec.Expect(db.Get(1)).ToNotBeNil()
+	spec.Expect(db.Get(2).(*Product).Name).ToEqual("after")
+}
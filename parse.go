@@ -0,0 +1,356 @@
+package nabu
+
+import (
+	"github.com/karlseguin/nabu/conditions"
+	"github.com/karlseguin/nabu/indexes"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Returned when a textual query can't be parsed. Position is the
+// byte offset within the query where the problem was found
+type ParseError struct {
+	Position int
+	Message  string
+}
+
+func (e *ParseError) Error() string {
+	return "nabu: " + e.Message + " at position " + strconv.Itoa(e.Position)
+}
+
+/*
+Builds a Query from its textual form:
+
+	sort:created desc where age>20 and gender=f and tag in (a,b) limit 10 offset 20
+
+Conditions are joined by and. >, >=, <, <= and "between 1 and 5" filter a
+sorted index. Conditions on the sort index are combined into a single
+range. = filters a sorted index when given an integer and the
+index is sorted, otherwise it filters a set, as does in (a,b). not in
+(a,b) excludes the documents in any of the set's values. Values
+containing spaces or punctuation are double-quoted. total includes the
+total count. Only the allowed index names can be sorted or filtered on;
+when none are given, every index is allowed. Sorting on an index which
+isn't a sort index, a limit below 1, an offset below 0 and queries
+beyond the configured MaxLimit or MaxConditionsPerQuery are rejected.
+*/
+func Parse(db *Database, text string, allowed ...string) (Query, error) {
+	p := &parser{db: db, text: text}
+	if len(allowed) > 0 {
+		p.allowed = make(map[string]bool, len(allowed))
+		for _, name := range allowed {
+			p.allowed[name] = true
+		}
+	}
+	return p.parse()
+}
+
+type tokenKind int
+
+const (
+	endToken tokenKind = iota
+	wordToken
+	quotedToken
+	symbolToken
+)
+
+type token struct {
+	kind     tokenKind
+	value    string
+	position int
+}
+
+type parser struct {
+	db       *Database
+	text     string
+	position int
+	allowed  map[string]bool
+	peeked   *token
+}
+
+func (p *parser) parse() (query Query, err error) {
+	t := p.next()
+	if t.kind != wordToken || strings.HasPrefix(strings.ToLower(t.value), "sort:") == false {
+		return nil, p.fail(t, "expected sort:INDEX")
+	}
+	name := t.value[5:]
+	if err := p.allow(name, t.position+5); err != nil {
+		return nil, err
+	}
+	if p.isRanked(name) == false {
+		return nil, &ParseError{t.position + 5, "unknown sort index " + strconv.Quote(name)}
+	}
+	where := make([]Condition, 0, 2)
+	desc, total, limit, offset, filters := false, false, -1, 0, 0
+	for t = p.next(); t.kind != endToken; t = p.next() {
+		if t.kind != wordToken {
+			return nil, p.fail(t, "unexpected "+t.describe())
+		}
+		switch strings.ToLower(t.value) {
+		case "asc":
+			desc = false
+		case "desc":
+			desc = true
+		case "total":
+			total = true
+		case "limit":
+			if limit, err = p.atLeast(1); err != nil {
+				return nil, err
+			}
+			if limit > p.db.maxLimit {
+				return nil, p.fail(t, "limit exceeds the maximum of "+strconv.Itoa(p.db.maxLimit))
+			}
+		case "offset":
+			if offset, err = p.atLeast(0); err != nil {
+				return nil, err
+			}
+		case "where":
+			for {
+				start := p.peek()
+				condition, err := p.condition()
+				if err != nil {
+					return nil, err
+				}
+				if condition.IndexName() != name {
+					if filters++; filters > p.db.maxConditionsPerQuery {
+						return nil, p.fail(start, "more than "+strconv.Itoa(p.db.maxConditionsPerQuery)+" conditions")
+					}
+				}
+				where = append(where, condition)
+				if next := p.peek(); next.kind != wordToken || strings.ToLower(next.value) != "and" {
+					break
+				}
+				p.next()
+			}
+		default:
+			return nil, p.fail(t, "unexpected "+t.describe())
+		}
+	}
+
+	query = p.db.Query(name)
+	for _, condition := range where {
+		query.Where(condition)
+	}
+	if desc {
+		query.Desc()
+	}
+	if limit != -1 {
+		query.Limit(limit)
+	}
+	if total {
+		query.IncludeTotal()
+	}
+	return query.Offset(offset), nil
+}
+
+// Parses a single condition, such as age>20 or tag in (a,b)
+func (p *parser) condition() (Condition, error) {
+	t := p.next()
+	if t.kind != wordToken {
+		return nil, p.fail(t, "expected an index name, got "+t.describe())
+	}
+	name := t.value
+	if err := p.allow(name, t.position); err != nil {
+		return nil, err
+	}
+	op := p.next()
+	switch {
+	case op.kind == symbolToken && op.value == "=":
+		value := p.next()
+		if value.kind != wordToken && value.kind != quotedToken {
+			return nil, p.fail(value, "expected a value, got "+value.describe())
+		}
+		if n, err := strconv.Atoi(value.value); err == nil && value.kind == wordToken && p.isRanked(name) {
+			return EQ(name, n), nil
+		}
+		return conditions.NewSet(name, value.value), nil
+	case op.kind == symbolToken:
+		n, err := p.integer()
+		if err != nil {
+			return nil, err
+		}
+		switch op.value {
+		case ">":
+			return GT(name, n), nil
+		case ">=":
+			return GTE(name, n), nil
+		case "<":
+			return LT(name, n), nil
+		case "<=":
+			return LTE(name, n), nil
+		}
+	case op.kind == wordToken && strings.ToLower(op.value) == "between":
+		from, err := p.integer()
+		if err != nil {
+			return nil, err
+		}
+		if and := p.next(); and.kind != wordToken || strings.ToLower(and.value) != "and" {
+			return nil, p.fail(and, "expected and, got "+and.describe())
+		}
+		to, err := p.integer()
+		if err != nil {
+			return nil, err
+		}
+		return Between(name, from, to), nil
+	case op.kind == wordToken && strings.ToLower(op.value) == "in":
+		values, err := p.list()
+		if err != nil {
+			return nil, err
+		}
+		if len(values) == 1 {
+			return conditions.NewSet(name, values[0]), nil
+		}
+		return conditions.NewUnion(name, values), nil
+	case op.kind == wordToken && strings.ToLower(op.value) == "not":
+		if in := p.next(); in.kind != wordToken || strings.ToLower(in.value) != "in" {
+			return nil, p.fail(in, "expected in, got "+in.describe())
+		}
+		values, err := p.list()
+		if err != nil {
+			return nil, err
+		}
+		return conditions.NewNotIn(name, values), nil
+	}
+	return nil, p.fail(op, "expected an operator, got "+op.describe())
+}
+
+// Parses a parenthesized, comma separated, list of values
+func (p *parser) list() ([]string, error) {
+	if t := p.next(); t.kind != symbolToken || t.value != "(" {
+		return nil, p.fail(t, "expected (, got "+t.describe())
+	}
+	values := make([]string, 0, 4)
+	for {
+		t := p.next()
+		if t.kind != wordToken && t.kind != quotedToken {
+			return nil, p.fail(t, "expected a value, got "+t.describe())
+		}
+		values = append(values, t.value)
+		t = p.next()
+		if t.kind == symbolToken && t.value == ")" {
+			return values, nil
+		}
+		if t.kind != symbolToken || t.value != "," {
+			return nil, p.fail(t, "expected , or ), got "+t.describe())
+		}
+	}
+}
+
+func (p *parser) integer() (int, error) {
+	t := p.next()
+	n, err := strconv.Atoi(t.value)
+	if t.kind != wordToken || err != nil {
+		return 0, p.fail(t, "expected an integer, got "+t.describe())
+	}
+	return n, nil
+}
+
+// Parses an integer no smaller than min
+func (p *parser) atLeast(min int) (int, error) {
+	t := p.peek()
+	n, err := p.integer()
+	if err == nil && n < min {
+		return 0, p.fail(t, "expected an integer of at least "+strconv.Itoa(min)+", got "+t.describe())
+	}
+	return n, err
+}
+
+func (p *parser) allow(name string, position int) error {
+	if len(name) == 0 {
+		return &ParseError{position, "expected an index name"}
+	}
+	if p.allowed != nil && p.allowed[name] == false {
+		return &ParseError{position, "index " + strconv.Quote(name) + " cannot be queried"}
+	}
+	if p.db.schema != nil && p.db.schema.declares(name) == false && p.db.isCompositeColumn(name) == false {
+		return &ParseError{position, "index " + strconv.Quote(name) + " is not declared"}
+	}
+	return nil
+}
+
+func (p *parser) isRanked(name string) bool {
+	if kind, declared := p.db.schema[name]; declared {
+		return kind == SortedIntKind || kind == SortedStringKind
+	}
+	index, _ := p.db.getIndex(name)
+	_, ranked := index.(indexes.Ranked)
+	return ranked
+}
+
+func (p *parser) fail(t *token, message string) error {
+	return &ParseError{t.position, message}
+}
+
+func (p *parser) peek() *token {
+	if p.peeked == nil {
+		p.peeked = p.scan()
+	}
+	return p.peeked
+}
+
+func (p *parser) next() *token {
+	t := p.peek()
+	p.peeked = nil
+	return t
+}
+
+// Reads the next token from the text
+func (p *parser) scan() *token {
+	text := p.text
+	for p.position < len(text) && isSpace(text[p.position]) {
+		p.position++
+	}
+	start := p.position
+	if start == len(text) {
+		return &token{endToken, "", start}
+	}
+	switch c := text[start]; c {
+	case '(', ')', ',', '=':
+		p.position++
+		return &token{symbolToken, text[start:p.position], start}
+	case '>', '<':
+		p.position++
+		if p.position < len(text) && text[p.position] == '=' {
+			p.position++
+		}
+		return &token{symbolToken, text[start:p.position], start}
+	case '"':
+		for p.position++; p.position < len(text); p.position++ {
+			if text[p.position] == '\\' {
+				p.position++
+			} else if text[p.position] == '"' {
+				p.position++
+				if value, err := strconv.Unquote(text[start:p.position]); err == nil {
+					return &token{quotedToken, value, start}
+				}
+				break
+			}
+		}
+		return &token{symbolToken, text[start:p.position], start}
+	}
+	for p.position < len(text) {
+		r, size := utf8.DecodeRuneInString(text[p.position:])
+		if conditions.IsWordRune(r) == false {
+			break
+		}
+		p.position += size
+	}
+	if p.position == start {
+		_, size := utf8.DecodeRuneInString(text[start:])
+		p.position += size
+		return &token{symbolToken, text[start:p.position], start}
+	}
+	return &token{wordToken, text[start:p.position], start}
+}
+
+func (t *token) describe() string {
+	if t.kind == endToken {
+		return "end of query"
+	}
+	return strconv.Quote(t.value)
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r'
+}
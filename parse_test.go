@@ -0,0 +1,135 @@
+package nabu
+
+import (
+	"github.com/karlseguin/gspec"
+	"testing"
+)
+
+func TestParseBuildsAQuery(t *testing.T) {
+	db := ParseDB()
+	defer db.Close()
+	query, err := Parse(db, "sort:created desc where age>20 and gender=f and tag in (a,b) limit 2 offset 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertClosedResult(t, query.Execute(), 5, 3)
+}
+
+func TestParseRoundTripsThroughString(t *testing.T) {
+	spec := gspec.New(t)
+	db := ParseDB()
+	defer db.Close()
+	for _, text := range []string{
+		"sort:created",
+		"sort:created desc where age>=21 and age<=40 limit 5 total",
+		"sort:created where age between 20 and 30 and created<4 offset 2",
+		`sort:created where gender=f and tag in (a,"b c","and") and age=25`,
+		`sort:created where gender="10" and tag=x`,
+	} {
+		query, err := Parse(db, text)
+		spec.Expect(err).ToBeNil()
+		parsed := query.String()
+		query.Execute().Close()
+		spec.Expect(parsed).ToEqual(text)
+	}
+}
+
+func TestParseDistinguishesEqualityFromSets(t *testing.T) {
+	spec := gspec.New(t)
+	db := ParseDB()
+	defer db.Close()
+	query, _ := Parse(db, "sort:created where age=25")
+	spec.Expect(query.(*NormalQuery).conditions[0].Key()).ToEqual("age=25")
+	query.Execute().Close()
+	query, _ = Parse(db, "sort:created where gender=f")
+	spec.Expect(query.(*NormalQuery).conditions[0].Key()).ToEqual("gender=s=f")
+	query.Execute().Close()
+}
+
+func TestParseReportsErrorPositions(t *testing.T) {
+	spec := gspec.New(t)
+	db := ParseDB()
+	defer db.Close()
+	for text, expected := range map[string]string{
+		"created where age>1":                   "nabu: expected sort:INDEX at position 0",
+		"sort:created where age>x":              "nabu: expected an integer, got \"x\" at position 23",
+		"sort:created where age ! 3":            "nabu: expected an operator, got \"!\" at position 23",
+		"sort:created where tag in (a,b":        "nabu: expected , or ), got end of query at position 30",
+		"sort:created limit 10 order":           "nabu: unexpected \"order\" at position 22",
+		"sort:created where age between 1 or 2": "nabu: expected and, got \"or\" at position 33",
+		"sort:created where secret=1":           "nabu: index \"secret\" cannot be queried at position 19",
+		"sort:secret":                           "nabu: index \"secret\" cannot be queried at position 5",
+		"sort:created limit 101":                "nabu: limit exceeds the maximum of 100 at position 13",
+		"sort:created limit 0":                  "nabu: expected an integer of at least 1, got \"0\" at position 19",
+		"sort:created offset -1":                "nabu: expected an integer of at least 0, got \"-1\" at position 20",
+		"sort:tag":                              "nabu: unknown sort index \"tag\" at position 5",
+	} {
+		_, err := Parse(db, text, "created", "age", "tag", "gender")
+		spec.Expect(err.Error()).ToEqual(expected)
+		spec.Expect(len(db.queryPool)).ToEqual(1)
+	}
+}
+
+func TestParseLimitsTheNumberOfConditions(t *testing.T) {
+	spec := gspec.New(t)
+	db := New(SmallConfig().MaxConditionsPerQuery(2))
+	defer db.Close()
+	makeIndex(db, "created", 1)
+	_, err := Parse(db, "sort:created where created>1 and a>1 and b>2 and c>3")
+	spec.Expect(err.Error()).ToEqual("nabu: more than 2 conditions at position 49")
+}
+
+func TestParseCombinesConditionsOnTheSortIndex(t *testing.T) {
+	spec := gspec.New(t)
+	db := ParseDB()
+	defer db.Close()
+	query, err := Parse(db, "sort:created where created>1 and gender=f and created<=5")
+	spec.Expect(err).ToBeNil()
+	text := query.String()
+	spec.Expect(text).ToEqual("sort:created where gender=f and created between 2 and 5")
+	assertClosedResult(t, query.Execute(), 2, 3, 5)
+
+	query, err = Parse(db, text)
+	spec.Expect(err).ToBeNil()
+	spec.Expect(query.String()).ToEqual(text)
+	assertClosedResult(t, query.Execute(), 2, 3, 5)
+
+	query, _ = Parse(db, "sort:created where created>5 and created<3")
+	assertClosedResult(t, query.Execute())
+}
+
+func ParseDB() *Database {
+	db := New(SmallConfig())
+	db.Update(&ParseDoc{NewDoc(1, map[string]int{"created": 1, "age": 25}), "f", "a"})
+	db.Update(&ParseDoc{NewDoc(2, map[string]int{"created": 2, "age": 19}), "f", "a"})
+	db.Update(&ParseDoc{NewDoc(3, map[string]int{"created": 3, "age": 30}), "f", "b"})
+	db.Update(&ParseDoc{NewDoc(4, map[string]int{"created": 4, "age": 40}), "m", "a"})
+	db.Update(&ParseDoc{NewDoc(5, map[string]int{"created": 5, "age": 50}), "f", "a"})
+	db.Update(&ParseDoc{NewDoc(6, map[string]int{"created": 6, "age": 60}), "f", "c"})
+	db.Update(&ParseDoc{NewDoc(7, map[string]int{"created": 7, "age": 70}), "f", "b"})
+	return db
+}
+
+type ParseDoc struct {
+	*Doc
+	gender string
+	tag    string
+}
+
+func (d *ParseDoc) ReadMeta(meta *Meta) {
+	d.Doc.ReadMeta(meta)
+	meta.Set("gender", d.gender, false)
+	meta.Set("tag", d.tag, false)
+}
+
+func TestParseExcludesSetValues(t *testing.T) {
+	spec := gspec.New(t)
+	db := ParseDB()
+	defer db.Close()
+	query, err := Parse(db, "sort:created where tag not in (a,b)")
+	spec.Expect(err).ToBeNil()
+	spec.Expect(query.String()).ToEqual("sort:created where tag not in (a,b)")
+	query.Execute().Close()
+	_, err = Parse(db, "sort:created where tag not (a)")
+	spec.Expect(err.Error()).ToEqual("nabu: expected in, got \"(\" at position 27")
+}
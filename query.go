@@ -1,22 +1,36 @@
 package nabu
 
 import (
+	"fmt"
 	"github.com/karlseguin/nabu/conditions"
 	"github.com/karlseguin/nabu/indexes"
 	"github.com/karlseguin/nabu/key"
 	"sort"
+	"strconv"
+	"strings"
 )
 
 type Query interface {
 	NoCache() Query
 	Union(name string, values ...string) Query
 	Set(name, value string) Query
+	NotSet(name, value string) Query
+	Boost(condition Condition, weight int) Query
 	Where(condition Condition) Query
 	Desc() Query
 	Limit(limit int) Query
 	Offset(offset int) Query
 	IncludeTotal() Query
+	Project(indexNames ...string) Query
 	Execute() Result
+	Iterate(fn func(id uint, doc Document) bool)
+	Exact() Query
+	Count() int
+	Sample(n int) Query
+	Shuffle(seed int64) Query
+	DistinctBy(name string, perValue int) Query
+	String() string
+	Err() error
 }
 
 // Build and executes a query against the database
@@ -35,14 +49,30 @@ type NormalQuery struct {
 	dynamicSort    []uint
 	ranged         bool
 	conditions     Conditions
+	err            error
+	boosts         Conditions
+	weights        []int
+	boostCount     int
+	now            int64
+	snapshot       *Snapshot
+	projected      []string
+	exact          bool
+	random         bool
+	seed           int64
+	distinctBy     string
+	distinctLimit  int
+	distinctCounts map[string]int
 }
 
 // Queries are statically created upfront and reused
 func newQuery(db *Database) Query {
 	q := &NormalQuery{
-		db:         db,
-		cache:      true,
-		conditions: make(Conditions, db.maxConditionsPerQuery),
+		db:             db,
+		cache:          true,
+		conditions:     make(Conditions, db.maxConditionsPerQuery),
+		boosts:         make(Conditions, db.maxConditionsPerQuery),
+		weights:        make([]int, db.maxConditionsPerQuery),
+		distinctCounts: make(map[string]int),
 	}
 	q.reset()
 	return q
@@ -54,6 +84,12 @@ func (q *NormalQuery) Set(indexName, value string) Query {
 	return q
 }
 
+// Exclude documents in the set.
+func (q *NormalQuery) NotSet(indexName, value string) Query {
+	q.addCondition(conditions.NewNotIn(indexName, []string{value}))
+	return q
+}
+
 // Filter on an a union of set values (tag1 || tag2 || tag3).
 func (q *NormalQuery) Union(indexName string, values ...string) Query {
 	if len(values) == 1 {
@@ -66,12 +102,12 @@ func (q *NormalQuery) Union(indexName string, values ...string) Query {
 // Filter results for the query and value. Where can be called multiple
 // times. Each must have an even number of parameters (indexName, value):
 //
-//    Where(nabu.GT("age", 10))
-//
+//	Where(nabu.GT("age", 10))
 func (q *NormalQuery) Where(condition Condition) Query {
+	q.validate(condition)
 	if q.sort != nil && condition.IndexName() == q.sort.Name() {
 		if ranked, ok := condition.(RankedCondition); ok {
-			q.sortCondition = ranked
+			q.sortCondition = combineRanges(q.sortCondition, ranked)
 		}
 	} else {
 		q.addCondition(condition)
@@ -79,7 +115,25 @@ func (q *NormalQuery) Where(condition Condition) Query {
 	return q
 }
 
+// Narrows the existing condition on the sort index (if any) to the
+// range of the other
+func combineRanges(existing RankedCondition, other RankedCondition) RankedCondition {
+	if existing == nil {
+		return other
+	}
+	from, to := existing.Range()
+	otherFrom, otherTo := other.Range()
+	if otherFrom > from {
+		from = otherFrom
+	}
+	if otherTo < to {
+		to = otherTo
+	}
+	return conditions.NewBetween(other.IndexName(), from, to)
+}
+
 func (q *NormalQuery) addCondition(condition Condition) bool {
+	q.validate(condition)
 	if q.conditionCount == q.db.maxConditionsPerQuery {
 		return false
 	}
@@ -88,6 +142,40 @@ func (q *NormalQuery) addCondition(condition Condition) bool {
 	return true
 }
 
+// Records a *SchemaError when the condition is on an undeclared index
+func (q *NormalQuery) validate(condition Condition) {
+	if q.db.schema == nil || q.err != nil {
+		return
+	}
+	if multi, ok := condition.(MultiCondition); ok {
+		for _, indexName := range multi.IndexNames() {
+			q.validateIndex(indexName)
+		}
+	} else {
+		q.validateIndex(condition.IndexName())
+	}
+}
+
+func (q *NormalQuery) validateIndex(indexName string) {
+	if q.db.schema.declares(indexName) {
+		return
+	}
+	name := indexName
+	if index := strings.IndexByte(indexName, '='); index != -1 {
+		name = indexName[:index]
+	}
+	if q.db.isCompositeColumn(name) == false {
+		q.err = &SchemaError{Index: name}
+	}
+}
+
+// The error, such as a *SchemaError for a condition on an undeclared
+// index, which leaves the query without results. Must be checked before
+// the query is executed
+func (q *NormalQuery) Err() error {
+	return q.err
+}
+
 // Don't cache the result or use the cache to generate the result
 // Caches are incrementally updated as changes come in, so this should
 // only be used for one-off queries
@@ -131,15 +219,105 @@ func (q *NormalQuery) IncludeTotal() Query {
 	return q
 }
 
+// Keep only the first perValue documents, in sort order, for each value
+// of the named set dimension (the name given to Meta.Set). Documents
+// without a value for the dimension are always kept. Snapshot queries
+// group documents by their current value
+func (q *NormalQuery) DistinctBy(name string, perValue int) Query {
+	q.distinctBy = name
+	q.distinctLimit = perValue
+	return q
+}
+
+// Include each document's score in the named sort indexes, available
+// via the result's Score
+func (q *NormalQuery) Project(indexNames ...string) Query {
+	q.projected = indexNames
+	return q
+}
+
+// The query in the textual form understood by Parse. Options which
+// Parse doesn't support are omitted
+func (q *NormalQuery) String() string {
+	parts := make([]string, 0, 6)
+	if q.sort != nil {
+		parts = append(parts, "sort:"+q.sort.Name())
+	}
+	if q.desc {
+		parts = append(parts, "desc")
+	}
+	where := make([]string, 0, q.conditionCount+1)
+	for _, condition := range q.conditions[:q.conditionCount] {
+		where = append(where, conditionString(condition))
+	}
+	if q.sortCondition != nil {
+		where = append(where, conditionString(q.sortCondition))
+	}
+	if len(where) > 0 {
+		parts = append(parts, "where "+strings.Join(where, " and "))
+	}
+	if q.limit != q.db.defaultLimit {
+		parts = append(parts, "limit "+strconv.Itoa(q.limit))
+	}
+	if q.offset != 0 {
+		parts = append(parts, "offset "+strconv.Itoa(q.offset))
+	}
+	if q.includeTotal {
+		parts = append(parts, "total")
+	}
+	return strings.Join(parts, " ")
+}
+
+func conditionString(condition Condition) string {
+	if stringer, ok := condition.(fmt.Stringer); ok {
+		return stringer.String()
+	}
+	return condition.Key()
+}
+
 // Executes the query, returning a result. The result must be closed
 // once you are done with it
 func (q *NormalQuery) Execute() Result {
+	if snapshot := q.snapshot; snapshot != nil {
+		return &SnapshotResult{q.run(), snapshot}
+	}
+	q.db.txnLock.RLock()
+	defer q.db.txnLock.RUnlock()
+	return q.run()
+}
+
+func (q *NormalQuery) run() Result {
 	defer q.reset()
+	result := q.find()
+	if projector, ok := result.(projector); ok {
+		for _, name := range q.projected {
+			if index, exists := q.getIndex(name).(indexes.Ranked); exists {
+				projector.project(name, index, result.Ids())
+			}
+		}
+	}
+	return result
+}
+
+// A result which can hold the scores of projected indexes
+type projector interface {
+	project(indexName string, index indexes.Ranked, ids []uint)
+}
+
+func (q *NormalQuery) find() Result {
+	if q.err != nil {
+		return EmptyResult
+	}
+	q.useComposites()
 	conditionCount := q.conditionCount
+	q.now = q.db.expiry.now()
 
 	if q.dynamicSort != nil {
 		q.prepareConditions()
 		defer q.conditions[:conditionCount].RUnlock()
+		if q.random {
+			return q.findRandom()
+		}
 		return q.findFromDynamicSort()
 	}
 
@@ -152,7 +330,23 @@ func (q *NormalQuery) Execute() Result {
 	}
 	q.sort.RUnlock()
 
+	if q.random {
+		q.prepareConditions()
+		defer q.conditions[:conditionCount].RUnlock()
+		return q.findRandom()
+	}
+
+	if q.boostCount > 0 && q.distinctBy == "" {
+		q.prepareConditions()
+		defer q.conditions[:conditionCount].RUnlock()
+		return q.findByRelevance()
+	}
+
 	if conditionCount == 0 {
+		if q.now != 0 || q.distinctBy != "" {
+			// expired and collapsed documents throw off the offset and total
+			return q.findBySort()
+		}
 		return q.findWithNoIndexes()
 	}
 
@@ -161,10 +355,23 @@ func (q *NormalQuery) Execute() Result {
 	return q.execute()
 }
 
+// Gets an index from the query's snapshot, or the database
+func (q *NormalQuery) getIndex(name string) indexes.Index {
+	if q.snapshot != nil {
+		return q.snapshot.indexes[name]
+	}
+	index, _ := q.db.getIndex(name)
+	return index
+}
+
 // Loads the indexes used by the query
 func (q *NormalQuery) prepareConditions() {
 	conditionCount := q.conditionCount
-	q.db.LoadIndexes(q.conditions[:conditionCount])
+	if q.snapshot != nil {
+		loadIndexes(q.snapshot.indexes, q.conditions[:conditionCount])
+	} else {
+		q.db.LoadIndexes(q.conditions[:conditionCount])
+	}
 	q.conditions[:conditionCount].RLock()
 	if conditionCount > 1 {
 		sort.Sort(q.conditions[:conditionCount])
@@ -177,12 +384,16 @@ func (q *NormalQuery) prepareConditions() {
 // whether the smallest index is sufficiently small compared to the sort index.
 func (q *NormalQuery) execute() Result {
 	first := q.conditions[0]
+	if isNegated(first) {
+		// every condition is negated, none narrow down the sort
+		return q.findBySort()
+	}
 	firstLength := first.Len()
 	if firstLength == 0 {
 		return EmptyResult
 	}
 
-	if q.sortLength > firstLength*5 && firstLength <= q.db.maxUnsortedSize && first.CanIterate() {
+	if q.sortLength > firstLength*5 && firstLength <= q.db.maxUnsortedSize && first.CanIterate() && q.distinctBy == "" {
 		return q.findByIndex()
 	}
 	return q.findBySort()
@@ -192,7 +403,7 @@ func (q *NormalQuery) execute() Result {
 // a sort index)
 func (q *NormalQuery) findWithNoIndexes() Result {
 	limit := q.limit
-	result := <-q.db.sortedResults
+	result := q.db.sortedResult()
 	result.total = -1
 	var iterator indexes.Iterator
 	if q.desc {
@@ -207,7 +418,8 @@ func (q *NormalQuery) findWithNoIndexes() Result {
 
 	id := iterator.Current()
 	for ; id != key.NULL; id = iterator.Next() {
-		if result.add(id) == limit {
+		score, _ := q.sort.Score(id)
+		if result.add(id, score) == limit {
 			break
 		}
 	}
@@ -227,19 +439,25 @@ func (q *NormalQuery) findFromDynamicSort() Result {
 	found := 0
 	limit := q.limit
 	conditionCount := q.conditionCount
-	result := <-q.db.sortedResults
+	result := q.db.sortedResult()
 
-	for _, id := range q.dynamicSort {
+	for position, id := range q.dynamicSort {
 		keyd := key.Type(id)
+		if q.expired(keyd) {
+			goto nomatchdesc
+		}
 		for j := 0; j < conditionCount; j++ {
 			if q.conditions[j].Contains(keyd) == false {
 				goto nomatchdesc
 			}
 		}
+		if q.distinct(keyd) == false {
+			goto nomatchdesc
+		}
 		result.total++
 		if result.total > q.offset {
 			if found < limit {
-				result.add(keyd)
+				result.add(keyd, position)
 				found++
 			} else if result.total >= q.upto {
 				break
@@ -261,7 +479,7 @@ func (q *NormalQuery) findBySort() Result {
 	conditionCount := q.conditionCount
 	var iterator indexes.Iterator
 
-	result := <-q.db.sortedResults
+	result := q.db.sortedResult()
 	if q.desc {
 		iterator = q.sort.Backwards()
 	} else {
@@ -272,15 +490,22 @@ func (q *NormalQuery) findBySort() Result {
 	}
 
 	for id := iterator.Current(); id != key.NULL; id = iterator.Next() {
+		if q.expired(id) {
+			goto nomatchdesc
+		}
 		for j := 0; j < conditionCount; j++ {
 			if q.conditions[j].Contains(id) == false {
 				goto nomatchdesc
 			}
 		}
+		if q.distinct(id) == false {
+			goto nomatchdesc
+		}
 		result.total++
 		if result.total > q.offset {
 			if found < limit {
-				result.add(id)
+				score, _ := q.sort.Score(id)
+				result.add(id, score)
 				found++
 			} else if result.total >= q.upto {
 				break
@@ -300,7 +525,7 @@ func (q *NormalQuery) findBySort() Result {
 // smaller than the sort index
 func (q *NormalQuery) findByIndex() Result {
 	conditionCount := q.conditionCount
-	result := <-q.db.unsortedResults
+	result := q.db.unsortedResult()
 
 	var sort RankedContainer
 	if q.sortCondition != nil {
@@ -314,6 +539,9 @@ func (q *NormalQuery) findByIndex() Result {
 	defer iterator.Close()
 
 	for id := iterator.Current(); id != key.NULL; id = iterator.Next() {
+		if q.expired(id) {
+			goto nomatch
+		}
 		for j := 1; j < conditionCount; j++ {
 			if q.conditions[j].Contains(id) == false {
 				goto nomatch
@@ -327,17 +555,52 @@ func (q *NormalQuery) findByIndex() Result {
 	return result.finalize(q)
 }
 
+// Whether the document is within the first DistinctBy documents for its
+// value. Counts the document, so must be called once per match
+func (q *NormalQuery) distinct(id key.Type) bool {
+	if q.distinctBy == "" {
+		return true
+	}
+	value, exists := q.db.dimensions.get(q.distinctBy, id)
+	if exists == false {
+		return true
+	}
+	if q.distinctCounts[value] >= q.distinctLimit {
+		return false
+	}
+	q.distinctCounts[value]++
+	return true
+}
+
+// Whether the document expired before the query started executing
+func (q *NormalQuery) expired(id key.Type) bool {
+	return q.now != 0 && q.db.expiry.expired(id, q.now)
+}
+
 // Reset the query and release it back into the pool
 func (q *NormalQuery) reset() {
+	q.now = 0
 	q.sort = nil
+	q.snapshot = nil
 	q.offset = 0
 	q.cache = true
 	q.desc = false
 	q.ranged = false
 	q.dynamicSort = nil
 	q.conditionCount = 0
+	q.err = nil
+	q.boostCount = 0
 	q.sortCondition = nil
 	q.includeTotal = false
+	q.projected = nil
+	q.exact = false
+	q.random = false
+	if q.distinctBy != "" {
+		q.distinctBy = ""
+		for value, _ := range q.distinctCounts {
+			delete(q.distinctCounts, value)
+		}
+	}
 	q.limit = q.db.defaultLimit
 	q.upto = q.db.defaultLimit + 1
 	q.db.queryPool <- q
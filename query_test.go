@@ -3,6 +3,7 @@ package nabu
 import (
 	"github.com/karlseguin/gspec"
 	"testing"
+	"time"
 )
 
 func TestQueryCapsTheLimit(t *testing.T) {
@@ -399,30 +400,161 @@ func TestQueryWithUnion(t *testing.T) {
 	assertResult(t, result, 1, 2, 3, 4)
 }
 
+// Expiry
+func TestQueryExcludesExpiredDocuments(t *testing.T) {
+	db := New(SmallConfig())
+	db.Close()
+	for i := uint(1); i < 7; i++ {
+		expires := time.Now().Add(time.Hour)
+		if i%2 == 0 {
+			expires = time.Now().Add(-time.Second)
+		}
+		db.Update(NewExpiringDoc(i, expires, map[string]int{"created": int(i)}))
+	}
+	assertClosedResult(t, db.Query("created").Execute(), 1, 3, 5)
+	assertClosedResult(t, db.Query("created").Desc().Offset(1).Execute(), 3, 1)
+	assertClosedResult(t, db.Query("created").Where(GT("created", 1)).Execute(), 3, 5)
+	assertClosedResult(t, db.DynamicQuery([]uint{6, 5, 4, 3}).Execute(), 5, 3)
+}
+
+func TestQueryTotalExcludesExpiredDocuments(t *testing.T) {
+	spec := gspec.New(t)
+	db := New(SmallConfig())
+	db.Close()
+	db.Update(NewExpiringDoc(1, time.Now().Add(-time.Second), map[string]int{"created": 1}))
+	db.Update(NewDoc(2, map[string]int{"created": 2}))
+	result := db.Query("created").IncludeTotal().Execute()
+	defer result.Close()
+	spec.Expect(result.Total()).ToEqual(1)
+}
+
 // Dynamic Sort
 func TestQueryWithDynamicSort(t *testing.T) {
 	db := New(SmallConfig())
 	db.Close()
 	makeIndex(db, "created", 1, 2, 3, 4, 5, 6, 7)
-	result := db.DynamicQuery([]uint{2,3,5,6}).Execute()
-	assertResult(t, result, 2,3,5,6)
+	result := db.DynamicQuery([]uint{2, 3, 5, 6}).Execute()
+	assertResult(t, result, 2, 3, 5, 6)
 }
 
 func TestQueryWithDynamicSortAndFilter(t *testing.T) {
 	db := New(SmallConfig())
 	db.Close()
 	makeIndex(db, "created", 1, 2, 3, 4, 5, 6, 7, 8, 9, 10)
-	result := db.DynamicQuery([]uint{2,3,5,6,9,10}).Where(GT("created", 5)).Execute()
-	assertResult(t, result, 6,9,10)
+	result := db.DynamicQuery([]uint{2, 3, 5, 6, 9, 10}).Where(GT("created", 5)).Execute()
+	assertResult(t, result, 6, 9, 10)
 }
 
-
 func TestQueryWithDynamicSortWithPaging(t *testing.T) {
 	db := New(SmallConfig())
 	db.Close()
 	makeIndex(db, "created", 1, 2, 3, 4, 5, 6, 7, 8, 9, 10)
-	result := db.DynamicQuery([]uint{2,3,5,6,9,10}).Offset(2).Limit(2).Execute()
-	assertResult(t, result, 5,6)
+	result := db.DynamicQuery([]uint{2, 3, 5, 6, 9, 10}).Offset(2).Limit(2).Execute()
+	assertResult(t, result, 5, 6)
+}
+
+// Scores
+func TestQueryBySortIncludesScores(t *testing.T) {
+	spec := gspec.New(t)
+	db := New(SmallConfig())
+	db.Close()
+	db.Update(NewDoc(1, map[string]int{"price": 30}))
+	db.Update(NewDoc(2, map[string]int{"price": 10}))
+	db.Update(NewDoc(3, map[string]int{"price": 20}))
+	result := db.Query("price").Desc().Execute()
+	defer result.Close()
+	spec.Expect(len(result.Scores())).ToEqual(3)
+	spec.Expect(result.Scores()[0]).ToEqual(30)
+	spec.Expect(result.Scores()[1]).ToEqual(20)
+	spec.Expect(result.Scores()[2]).ToEqual(10)
+}
+
+func TestQueryByIndexIncludesScores(t *testing.T) {
+	spec := gspec.New(t)
+	db := New(SmallConfig())
+	db.Close()
+	makeIndex(db, "created", 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15)
+	makeSet(db, "city=dune", 12, 4)
+	result := db.Query("created").Set("city", "dune").Execute()
+	defer result.Close()
+	assertResult(t, result, 4, 12)
+	spec.Expect(result.Scores()[0]).ToEqual(4)
+	spec.Expect(result.Scores()[1]).ToEqual(12)
+}
+
+func TestQueryProjectsScoresOfOtherIndexes(t *testing.T) {
+	spec := gspec.New(t)
+	db := New(SmallConfig())
+	db.Close()
+	db.Update(NewDoc(1, map[string]int{"created": 1, "rank": 8}))
+	db.Update(NewDoc(2, map[string]int{"created": 2}))
+	result := db.Query("created").Project("rank", "missing").Execute()
+	defer result.Close()
+	score, exists := result.Score("rank", 0)
+	spec.Expect(score).ToEqual(8)
+	spec.Expect(exists).ToEqual(true)
+	_, exists = result.Score("rank", 1)
+	spec.Expect(exists).ToEqual(false)
+	_, exists = result.Score("missing", 0)
+	spec.Expect(exists).ToEqual(false)
+}
+
+// Distinct
+func TestQueryDistinctByKeepsTheFirstDocumentsPerValue(t *testing.T) {
+	db := BrandDB()
+	defer db.Close()
+	assertClosedResult(t, db.Query("created").DistinctBy("brand", 1).Execute(), 1, 2, 5, 7)
+	assertClosedResult(t, db.Query("created").DistinctBy("brand", 1).Desc().Execute(), 7, 6, 4, 3)
+}
+
+func TestQueryDistinctByRespectsPaging(t *testing.T) {
+	spec := gspec.New(t)
+	db := BrandDB()
+	defer db.Close()
+	result := db.Query("created").DistinctBy("brand", 1).Limit(2).Offset(1).IncludeTotal().Execute()
+	defer result.Close()
+	assertResult(t, result, 2, 5)
+	spec.Expect(result.HasMore()).ToEqual(true)
+	spec.Expect(result.Total()).ToEqual(4)
+}
+
+func TestQueryDistinctByWithConditions(t *testing.T) {
+	spec := gspec.New(t)
+	db := BrandDB()
+	defer db.Close()
+	assertClosedResult(t, db.Query("created").Set("brand", "b").DistinctBy("brand", 1).Execute(), 2)
+	assertClosedResult(t, db.Query("created").Where(GT("created", 2)).DistinctBy("brand", 1).Execute(), 3, 4, 5, 7)
+	spec.Expect(db.Query("created").DistinctBy("brand", 1).Count()).ToEqual(4)
+}
+
+func TestQueryDistinctByTracksUpdates(t *testing.T) {
+	db := BrandDB()
+	defer db.Close()
+	db.Update(&BrandDoc{Doc: NewDoc(1, map[string]int{"created": 1}), brand: "c"})
+	assertClosedResult(t, db.Query("created").DistinctBy("brand", 1).Execute(), 1, 2, 3, 7)
+	db.RemoveById(1)
+	assertClosedResult(t, db.Query("created").DistinctBy("brand", 1).Execute(), 2, 3, 5, 7)
+}
+
+func BrandDB() *Database {
+	db := New(SmallConfig())
+	for i, brand := range []string{"a", "b", "a", "b", "c", "c", ""} {
+		id := uint(i + 1)
+		db.Update(&BrandDoc{Doc: NewDoc(id, map[string]int{"created": int(id)}), brand: brand})
+	}
+	return db
+}
+
+type BrandDoc struct {
+	*Doc
+	brand string
+}
+
+func (d *BrandDoc) ReadMeta(meta *Meta) {
+	d.Doc.ReadMeta(meta)
+	if len(d.brand) != 0 {
+		meta.Set("brand", d.brand, false)
+	}
 }
 
 func assertResult(t *testing.T, result Result, expected ...uint) {
@@ -437,6 +569,11 @@ func assertResult(t *testing.T, result Result, expected ...uint) {
 	}
 }
 
+func assertClosedResult(t *testing.T, result Result, expected ...uint) {
+	defer result.Close()
+	assertResult(t, result, expected...)
+}
+
 func largeSort(size int) []int {
 	s := make([]int, size)
 	for i := 0; i < size; i++ {
@@ -444,3 +581,35 @@ func largeSort(size int) []int {
 	}
 	return s
 }
+
+func TestQueryExcludesASet(t *testing.T) {
+	db := New(SmallConfig())
+	db.Close()
+	makeIndex(db, "created", 1, 2, 3, 4, 5, 6, 7)
+	makeSet(db, "hidden=true", 2, 5)
+	result := db.Query("created").NotSet("hidden", "true").Execute()
+	assertResult(t, result, 1, 3, 4, 6, 7)
+}
+
+func TestQueryExcludesNothingWhenTheSetIsEmpty(t *testing.T) {
+	db := New(SmallConfig())
+	db.Close()
+	makeIndex(db, "created", 1, 2, 3)
+	result := db.Query("created").NotSet("hidden", "true").Execute()
+	assertResult(t, result, 1, 2, 3)
+}
+
+func TestQueryChecksExclusionsAfterOtherConditions(t *testing.T) {
+	spec := gspec.New(t)
+	db := New(SmallConfig())
+	db.Close()
+	makeIndex(db, "created", 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12)
+	makeSet(db, "stock=out", 3)
+	makeSet(db, "color=red", 2, 3, 4)
+	query := db.Query("created").Where(NotIn("stock", "out", "low")).Set("color", "red").(*NormalQuery)
+	query.prepareConditions()
+	spec.Expect(query.conditions[0].Key()).ToEqual("color=s=red")
+	query.conditions[:2].RUnlock()
+	result := query.Execute()
+	assertResult(t, result, 2, 4)
+}
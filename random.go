@@ -0,0 +1,144 @@
+package nabu
+
+import (
+	"github.com/karlseguin/nabu/indexes"
+	"github.com/karlseguin/nabu/key"
+	"math/rand"
+)
+
+// A condition whose ids can be accessed by their position
+type positionalCondition interface {
+	At(position int) key.Type
+}
+
+// Return up to n random documents which match the query. Rather than
+// scanning every match, random positions are drawn from the smallest of
+// the sort index and the first condition until enough matches are found
+func (q *NormalQuery) Sample(n int) Query {
+	q.random = true
+	q.seed = rand.Int63()
+	return q.Limit(n)
+}
+
+// Order the documents randomly. The same seed, against unchanged indexes,
+// results in the same order, so Offset can be used to page through it
+func (q *NormalQuery) Shuffle(seed int64) Query {
+	q.random = true
+	q.seed = seed
+	return q
+}
+
+// Draws positions in a random order. Assumes the conditions are already
+// prepared (and read locked)
+func (q *NormalQuery) findRandom() Result {
+	found := 0
+	limit := q.limit
+	result := q.db.sortedResult()
+
+	var sort RankedContainer
+	if q.sort != nil {
+		q.sort.RLock()
+		defer q.sort.RUnlock()
+		sort = q.sort
+		if q.sortCondition != nil {
+			sort = q.sortCondition
+		}
+	}
+
+	at, length := q.randomSource()
+	shuffler := newShuffler(q.seed, length)
+	for position, ok := shuffler.next(); ok; position, ok = shuffler.next() {
+		id := at(position)
+		if id == key.NULL {
+			continue
+		}
+		score := position
+		if sort != nil {
+			var exists bool
+			if score, exists = sort.Score(id); exists == false {
+				continue
+			}
+		}
+		if q.matches(id) == false {
+			continue
+		}
+		result.total++
+		if result.total > q.offset {
+			if found < limit {
+				result.add(id, score)
+				found++
+			} else if result.total >= q.upto {
+				break
+			}
+		}
+	}
+	result.hasMore = result.total > (q.offset + q.limit)
+	if q.includeTotal == false {
+		result.total = -1
+	}
+	return result
+}
+
+// The positions random documents are drawn from: the dynamic ids, the
+// first condition when it's smaller than the sort index, or the (possibly
+// ranged) sort index
+func (q *NormalQuery) randomSource() (func(int) key.Type, int) {
+	if q.dynamicSort != nil {
+		return func(position int) key.Type {
+			return key.Type(q.dynamicSort[position])
+		}, len(q.dynamicSort)
+	}
+	if q.conditionCount > 0 {
+		first := q.conditions[0]
+		if positional, ok := first.(positionalCondition); ok && first.Len() < q.sortLength {
+			return positional.At, first.Len()
+		}
+	}
+	from := 0
+	if q.sortCondition != nil {
+		rangeFrom, _ := q.sortCondition.Range()
+		from = q.sort.GetRank(rangeFrom, true)
+	}
+	positional, ok := q.sort.(indexes.Positional)
+	if ok == false {
+		return nil, 0
+	}
+	return func(position int) key.Type {
+		return positional.At(from + position)
+	}, q.sortLength
+}
+
+// Draws each position in [0, length) once, in a random order. A lazy
+// Fisher-Yates shuffle which only remembers the positions it swapped
+type shuffler struct {
+	rng     *rand.Rand
+	drawn   int
+	length  int
+	swapped map[int]int
+}
+
+func newShuffler(seed int64, length int) *shuffler {
+	return &shuffler{
+		length:  length,
+		rng:     rand.New(rand.NewSource(seed)),
+		swapped: make(map[int]int),
+	}
+}
+
+func (s *shuffler) next() (int, bool) {
+	if s.drawn >= s.length {
+		return 0, false
+	}
+	i := s.drawn + s.rng.Intn(s.length-s.drawn)
+	position := s.at(i)
+	s.swapped[i] = s.at(s.drawn)
+	s.drawn++
+	return position, true
+}
+
+func (s *shuffler) at(i int) int {
+	if position, exists := s.swapped[i]; exists {
+		return position
+	}
+	return i
+}
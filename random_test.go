@@ -0,0 +1,85 @@
+package nabu
+
+import (
+	"github.com/karlseguin/gspec"
+	"testing"
+)
+
+func TestShufflerDrawsEachPositionOnce(t *testing.T) {
+	spec := gspec.New(t)
+	s := newShuffler(42, 50)
+	seen := make(map[int]bool)
+	for position, ok := s.next(); ok; position, ok = s.next() {
+		spec.Expect(seen[position]).ToEqual(false)
+		seen[position] = true
+	}
+	spec.Expect(len(seen)).ToEqual(50)
+}
+
+func TestShuffleIsDeterministicForASeed(t *testing.T) {
+	spec := gspec.New(t)
+	db := New(SmallConfig())
+	defer db.Close()
+	makeIndex(db, "created", 1, 2, 3, 4, 5, 6, 7, 8, 9, 10)
+	first := executeIds(db.Query("created").Shuffle(9).Limit(10))
+	spec.Expect(len(first)).ToEqual(10)
+	assertIds(t, executeIds(db.Query("created").Shuffle(9).Limit(10)), first...)
+	assertIds(t, executeIds(db.Query("created").Shuffle(9).Limit(4).Offset(4)), first[4:8]...)
+
+	seen := make(map[uint]bool)
+	for _, id := range first {
+		seen[id] = true
+	}
+	spec.Expect(len(seen)).ToEqual(10)
+}
+
+func TestShuffleAppliesConditions(t *testing.T) {
+	spec := gspec.New(t)
+	db := New(SmallConfig())
+	defer db.Close()
+	makeIndex(db, "created", 1, 2, 3, 4, 5, 6, 7, 8, 9, 10)
+	makeIndex(db, "age", 2, 4, 6, 8, 10)
+	result := db.Query("created").Where(GT("age", 3)).Where(LT("created", 9)).Shuffle(1).IncludeTotal().Limit(2).Execute()
+	defer result.Close()
+	spec.Expect(result.Total()).ToEqual(3)
+	spec.Expect(result.Len()).ToEqual(2)
+	spec.Expect(result.HasMore()).ToEqual(true)
+	for i, id := range result.Ids() {
+		spec.Expect(id == 4 || id == 6 || id == 8).ToEqual(true)
+		spec.Expect(result.Scores()[i]).ToEqual(int(id))
+	}
+}
+
+func TestSampleReturnsDistinctMatches(t *testing.T) {
+	spec := gspec.New(t)
+	db := New(SmallConfig())
+	defer db.Close()
+	makeIndex(db, "created", 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16)
+	makeSet(db, "city=dune", 3, 5, 7, 9, 20)
+	ids := executeIds(db.Query("created").Set("city", "dune").Sample(3))
+	spec.Expect(len(ids)).ToEqual(3)
+	seen := make(map[uint]bool)
+	for _, id := range ids {
+		spec.Expect(id == 3 || id == 5 || id == 7 || id == 9).ToEqual(true)
+		seen[id] = true
+	}
+	spec.Expect(len(seen)).ToEqual(3)
+	spec.Expect(len(executeIds(db.Query("created").Set("city", "dune").Sample(10)))).ToEqual(4)
+}
+
+func TestSampleADynamicQuery(t *testing.T) {
+	spec := gspec.New(t)
+	db := New(SmallConfig())
+	defer db.Close()
+	makeIndex(db, "age", 1, 2, 3, 4, 5, 6)
+	ids := executeIds(db.DynamicQuery([]uint{6, 2, 9, 4}).Where(GT("age", 0)).Sample(5))
+	spec.Expect(len(ids)).ToEqual(3)
+}
+
+func executeIds(query Query) []uint {
+	result := query.Execute()
+	defer result.Close()
+	ids := make([]uint, result.Len())
+	copy(ids, result.Ids())
+	return ids
+}
@@ -0,0 +1,80 @@
+package nabu
+
+import (
+	"github.com/karlseguin/nabu/indexes"
+	"github.com/karlseguin/nabu/key"
+)
+
+// Add weight to the score of documents which match the condition. A
+// boosted query is ordered by relevance: the document's score in the
+// sort index plus the weight of every boost it matches (Desc puts the
+// most relevant first). The result's scores are the relevances. Only
+// offset+limit documents are ranked, so no more than the configured
+// MaxUnsortedSize can be paged through. Boosts are ignored by Sample,
+// Shuffle, DistinctBy and dynamic sorts
+func (q *NormalQuery) Boost(condition Condition, weight int) Query {
+	if q.boostCount < len(q.boosts) {
+		q.validate(condition)
+		q.boosts[q.boostCount] = condition
+		q.weights[q.boostCount] = weight
+		q.boostCount++
+	}
+	return q
+}
+
+// The document's score plus the weight of the boosts it matches
+func (q *NormalQuery) relevance(id key.Type, score int) int {
+	for i, boost := range q.boosts[:q.boostCount] {
+		if boost.Contains(id) {
+			score += q.weights[i]
+		}
+	}
+	return score
+}
+
+// Scores every match, keeping the most relevant in a heap rather than
+// sorting them all. Walks the first condition when it's smaller than the
+// sort index, and the sort index otherwise. Assumes the conditions are
+// already prepared (and read locked)
+func (q *NormalQuery) findByRelevance() Result {
+	boosts := q.boosts[:q.boostCount]
+	if q.snapshot != nil {
+		loadIndexes(q.snapshot.indexes, boosts)
+	} else {
+		q.db.LoadIndexes(boosts)
+	}
+	boosts.RLock()
+	defer boosts.RUnlock()
+
+	var sort RankedContainer = q.sort
+	if q.sortCondition != nil {
+		sort = q.sortCondition
+	}
+
+	result := q.db.unsortedResult()
+	size := q.offset + q.limit
+	if size > len(result.original) {
+		size = len(result.original)
+	}
+
+	var iterator indexes.Iterator
+	if q.conditionCount > 0 && q.conditions[0].CanIterate() && q.conditions[0].Len() < q.sortLength {
+		iterator = q.conditions[0].Iterator()
+	} else {
+		iterator = q.sort.Forwards()
+		if q.sortCondition != nil {
+			iterator.Range(q.sortCondition.Range()).Offset(0)
+		}
+	}
+	defer iterator.Close()
+
+	for id := iterator.Current(); id != key.NULL; id = iterator.Next() {
+		if q.matches(id) == false {
+			continue
+		}
+		if score, exists := sort.Score(id); exists {
+			result.push(id, q.relevance(id, score), size, q.desc)
+		}
+	}
+	return result.rank(q)
+}
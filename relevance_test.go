@@ -0,0 +1,61 @@
+package nabu
+
+import (
+	"github.com/karlseguin/gspec"
+	"testing"
+)
+
+func TestBoostOrdersByRelevance(t *testing.T) {
+	spec := gspec.New(t)
+	db := New(SmallConfig())
+	db.Close()
+	makeIndex(db, "created", 1, 2, 3, 4, 5, 6, 7)
+	makeSet(db, "color=red", 2, 3)
+	result := db.Query("created").Desc().Limit(3).IncludeTotal().Boost(In("color", "red"), 10).Execute()
+	defer result.Close()
+	assertResult(t, result, 3, 2, 7)
+	spec.Expect(result.Scores()[0], result.Scores()[1], result.Scores()[2]).ToEqual(13, 12, 7)
+	spec.Expect(result.Total()).ToEqual(7)
+	spec.Expect(result.HasMore()).ToEqual(true)
+}
+
+func TestBoostPagesAscending(t *testing.T) {
+	spec := gspec.New(t)
+	db := New(SmallConfig())
+	db.Close()
+	makeIndex(db, "created", 1, 2, 3, 4, 5, 6, 7)
+	makeSet(db, "color=red", 1)
+	result := db.Query("created").Offset(5).Limit(3).Boost(In("color", "red"), 10).Execute()
+	defer result.Close()
+	assertResult(t, result, 7, 1)
+	spec.Expect(result.HasMore()).ToEqual(false)
+}
+
+func TestBoostAddsTheWeightOfEveryMatch(t *testing.T) {
+	db := New(SmallConfig())
+	db.Close()
+	makeIndex(db, "created", 1, 2, 3, 4, 5, 6, 7)
+	makeSet(db, "color=red", 1, 2)
+	makeSet(db, "size=xl", 1, 3)
+	result := db.Query("created").Desc().Limit(3).Boost(In("color", "red"), 4).Boost(In("size", "xl"), 4).Execute()
+	assertClosedResult(t, result, 1, 3, 7)
+}
+
+func TestBoostWalksASmallerCondition(t *testing.T) {
+	db := New(SmallConfig())
+	db.Close()
+	makeIndex(db, "created", largeSort(100)...)
+	makeSet(db, "tag=x", 5, 50, 90)
+	makeSet(db, "hot=true", 5)
+	result := db.Query("created").Set("tag", "x").Desc().Boost(In("hot", "true"), 100).Execute()
+	assertClosedResult(t, result, 5, 90, 50)
+}
+
+func TestBoostRespectsTheSortRange(t *testing.T) {
+	db := New(SmallConfig())
+	db.Close()
+	makeIndex(db, "created", 1, 2, 3, 4, 5, 6, 7)
+	makeSet(db, "color=red", 2)
+	result := db.Query("created").Where(GT("created", 3)).Where(NotIn("color", "blue")).Boost(In("color", "red"), 10).Execute()
+	assertClosedResult(t, result, 4, 5, 6, 7)
+}
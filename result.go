@@ -1,5 +1,10 @@
 package nabu
 
+import (
+	"github.com/karlseguin/nabu/indexes"
+	"github.com/karlseguin/nabu/key"
+)
+
 // A query's result. Close must be called once you are done with it
 type Result interface {
 	// The number of documents in the current results
@@ -17,6 +22,15 @@ type Result interface {
 	// The document ids
 	Ids() []uint
 
+	// Each document's score in the index the query was sorted by. For
+	// a DynamicQuery, the score is the document's position in the ids
+	Scores() []int
+
+	// The score of the ith document in an index requested via the query's
+	// Project. False when the index wasn't projected or doesn't contain
+	// the document
+	Score(indexName string, i int) (int, bool)
+
 	// Releases the result
 	Close()
 }
@@ -26,6 +40,7 @@ var EmptyResult = &emptyResult{}
 
 type emptyResult struct {
 	ids       []uint
+	scores    []int
 	documents []Document
 }
 
@@ -45,8 +60,55 @@ func (r *emptyResult) Ids() []uint {
 	return r.ids
 }
 
+func (r *emptyResult) Scores() []int {
+	return r.scores
+}
+
+func (r *emptyResult) Score(indexName string, i int) (int, bool) {
+	return 0, false
+}
+
 func (r *emptyResult) Documents() []Document {
 	return r.documents
 }
 
 func (r *emptyResult) Close() {}
+
+// Scores of a result's documents within the indexes a query projected
+type projections struct {
+	lookup map[string][]projectedScore
+}
+
+type projectedScore struct {
+	score  int
+	exists bool
+}
+
+func (p *projections) Score(indexName string, i int) (int, bool) {
+	scores, exists := p.lookup[indexName]
+	if exists == false || i < 0 || i >= len(scores) {
+		return 0, false
+	}
+	return scores[i].score, scores[i].exists
+}
+
+// Looks up the score of each id within the named index
+func (p *projections) project(indexName string, index indexes.Ranked, ids []uint) {
+	if p.lookup == nil {
+		p.lookup = make(map[string][]projectedScore)
+	}
+	scores := p.lookup[indexName][:0]
+	index.RLock()
+	for _, id := range ids {
+		score, exists := index.Score(key.Type(id))
+		scores = append(scores, projectedScore{score, exists})
+	}
+	index.RUnlock()
+	p.lookup[indexName] = scores
+}
+
+func (p *projections) reset() {
+	for name, scores := range p.lookup {
+		p.lookup[name] = scores[:0]
+	}
+}
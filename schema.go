@@ -0,0 +1,77 @@
+package nabu
+
+import (
+	"strconv"
+	"strings"
+)
+
+// The kind of an index, as declared in a Schema
+type IndexKind int
+
+const (
+	// Populated by Meta.SortedInt and LoadSortedInts
+	SortedIntKind IndexKind = iota + 1
+	// Populated by Meta.SortedString and BulkLoadSortedString
+	SortedStringKind
+	// Populated by Meta.Set(name, value, false)
+	SetKind
+	// Populated by Meta.Set(name, value, true)
+	BigSetKind
+	// Declared by Configuration.Composite or Meta.Composite rather than
+	// in a Schema
+	CompositeKind
+)
+
+func (k IndexKind) String() string {
+	switch k {
+	case SortedIntKind:
+		return "sorted int"
+	case SortedStringKind:
+		return "sorted string"
+	case SetKind:
+		return "set"
+	case BigSetKind:
+		return "big set"
+	case CompositeKind:
+		return "composite"
+	}
+	return "unknown"
+}
+
+// Declares the kind of each index. Sets are declared by name, without
+// their values (city rather than city=dune)
+type Schema map[string]IndexKind
+
+// Returned by Update when a document's meta doesn't match the schema
+type SchemaError struct {
+	Index    string
+	Declared IndexKind
+	Used     IndexKind
+}
+
+func (e *SchemaError) Error() string {
+	if e.Declared == 0 {
+		return "nabu: index " + strconv.Quote(e.Index) + " is not declared"
+	}
+	return "nabu: index " + strconv.Quote(e.Index) + " is declared as a " + e.Declared.String() + ", not a " + e.Used.String()
+}
+
+// Checks that the named index is declared as the given kind
+func (s Schema) check(name string, kind IndexKind) error {
+	if declared := s[name]; declared != kind {
+		return &SchemaError{name, declared, kind}
+	}
+	return nil
+}
+
+// Whether the index, which might be the name=value of a set, is declared
+func (s Schema) declares(indexName string) bool {
+	if _, exists := s[indexName]; exists {
+		return true
+	}
+	if index := strings.IndexByte(indexName, '='); index != -1 {
+		kind := s[indexName[:index]]
+		return kind == SetKind || kind == BigSetKind
+	}
+	return false
+}
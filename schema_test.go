@@ -0,0 +1,96 @@
+package nabu
+
+import (
+	"github.com/karlseguin/gspec"
+	"github.com/karlseguin/nabu/conditions"
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+)
+
+func TestSchemaRejectsMismatchedIndexes(t *testing.T) {
+	spec := gspec.New(t)
+	db := SchemaDB()
+	defer db.Close()
+	err := db.Update(&SchemaDoc{1, func(m *Meta) { m.SortedString("age", "old") }})
+	spec.Expect(err.Error()).ToEqual(`nabu: index "age" is declared as a sorted int, not a sorted string`)
+	err = db.Update(&SchemaDoc{1, func(m *Meta) { m.Set("city", "dune", true) }})
+	spec.Expect(err.Error()).ToEqual(`nabu: index "city" is declared as a set, not a big set`)
+	err = db.Update(&SchemaDoc{1, func(m *Meta) { m.SortedInt("power", 9001) }})
+	spec.Expect(err.Error()).ToEqual(`nabu: index "power" is not declared`)
+	spec.Expect(db.Get(1)).ToBeNil()
+	spec.Expect(len(db.Indexes())).ToEqual(0)
+
+	spec.Expect(db.Update(&SchemaDoc{1, func(m *Meta) { m.SortedInt("age", 20).Set("city", "dune", false) }})).ToBeNil()
+	assertIds(t, executeIds(db.Query("age").Where(conditions.NewSet("city", "dune"))), 1)
+}
+
+func TestSchemaRejectsBulkLoadsOfMismatchedIndexes(t *testing.T) {
+	spec := gspec.New(t)
+	db := SchemaDB()
+	defer db.Close()
+	db.LoadSortedInts("name", []uint{1}, []int{1})
+	db.BulkLoadSortedString("age", []string{"a"})
+	db.LoadSortedInts("trending", []uint{1}, []int{1})
+	spec.Expect(len(db.Indexes())).ToEqual(0)
+	db.LoadSortedInts("age", []uint{1}, []int{1})
+	spec.Expect(len(db.Indexes())).ToEqual(1)
+}
+
+func TestSchemaRejectsQueriesAgainstUndeclaredIndexes(t *testing.T) {
+	spec := gspec.New(t)
+	db := SchemaDB()
+	defer db.Close()
+	db.Update(&SchemaDoc{1, func(m *Meta) { m.SortedInt("age", 20) }})
+	spec.Expect(db.Query("power").Err().Error()).ToEqual(`nabu: index "power" is not declared`)
+	query := db.Query("age").Where(GT("power", 1))
+	spec.Expect(query.Err().Error()).ToEqual(`nabu: index "power" is not declared`)
+	spec.Expect(query.Execute().Len()).ToEqual(0)
+	query = db.Query("age").Set("city", "dune").Union("planet", "arrakis", "caladan")
+	spec.Expect(query.Err().Error()).ToEqual(`nabu: index "planet" is not declared`)
+	query.Execute().Close()
+	query = db.Query("age").Set("city", "dune").Where(GT("age", 1))
+	spec.Expect(query.Err()).ToBeNil()
+	assertIds(t, executeIds(query))
+
+	_, err := Parse(db, "sort:age where power>1")
+	spec.Expect(err.Error()).ToEqual(`nabu: index "power" is not declared at position 15`)
+	query, err = Parse(db, "sort:age where city=dune")
+	spec.Expect(err).ToBeNil()
+	query.Execute().Close()
+}
+
+func TestSchemaRestoresDocumentsWithoutUndeclaredIndexes(t *testing.T) {
+	spec := gspec.New(t)
+	dir, _ := ioutil.TempDir("", "nabu")
+	defer os.RemoveAll(dir)
+	config := Configure().QueryPoolSize(1).ResultsPoolSize(1, 1).DbPath(path.Join(dir, "data.db")).CodecIntFactory(productFactory)
+	db := New(config)
+	db.Update(&Product{Id: 1, Name: "spice", Rank: 3})
+	db.Close()
+
+	db = New(config.Schema(Schema{"popular": SortedIntKind}))
+	defer db.Close()
+	spec.Expect(db.Get(1).(*Product).Name).ToEqual("spice")
+	_, exists := db.getIndex("rank")
+	spec.Expect(exists).ToEqual(false)
+}
+
+type SchemaDoc struct {
+	id   uint
+	meta func(m *Meta)
+}
+
+func (d *SchemaDoc) ReadMeta(m *Meta) {
+	m.IntId(d.id)
+	d.meta(m)
+}
+
+func SchemaDB() *Database {
+	return New(SmallConfig().Schema(Schema{
+		"age":  SortedIntKind,
+		"name": SortedStringKind,
+		"city": SetKind,
+	}))
+}
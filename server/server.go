@@ -0,0 +1,201 @@
+// Exposes a Nabu database over HTTP, with JSON responses
+package server
+
+import (
+	"encoding/json"
+	"github.com/karlseguin/nabu"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// The largest document body accepted by PUT /documents/{id}
+const MAX_BODY_SIZE = 1 << 20
+
+// Serves:
+//
+//	GET    /query?sort=created&desc=true&where=age>20 and gender=f&limit=10&offset=0&total=true
+//	GET    /documents/{id}
+//	PUT    /documents/{id}   (the body is decoded by the database's factory as JSON)
+//	DELETE /documents/{id}
+//	GET    /indexes
+//
+// The where parameter uses the syntax of nabu.Parse and can be repeated.
+// documents=false returns only the ids. Queries beyond the database's
+// MaxLimit or MaxConditionsPerQuery are rejected
+type Server struct {
+	db      *nabu.Database
+	allowed []string
+}
+
+// Creates a server for the database. Only the allowed index names can be
+// sorted or filtered on; when none are given, every index is allowed
+func New(db *nabu.Database, allowed ...string) *Server {
+	return &Server{
+		db:      db,
+		allowed: allowed,
+	}
+}
+
+type queryResponse struct {
+	Ids       []uint          `json:"ids"`
+	Documents []nabu.Document `json:"documents,omitempty"`
+	Total     int             `json:"total"`
+	HasMore   bool            `json:"hasMore"`
+}
+
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch path := r.URL.Path; {
+	case path == "/query":
+		s.only(w, r, "GET", s.query)
+	case path == "/indexes":
+		s.only(w, r, "GET", s.indexes)
+	case strings.HasPrefix(path, "/documents/") && len(path) > 11:
+		id := path[11:]
+		switch r.Method {
+		case "GET":
+			s.get(w, id)
+		case "PUT":
+			s.put(w, r, id)
+		case "DELETE":
+			s.remove(w, id)
+		default:
+			reply(w, http.StatusMethodNotAllowed, &errorResponse{"method not allowed"})
+		}
+	default:
+		reply(w, http.StatusNotFound, &errorResponse{"not found"})
+	}
+}
+
+func (s *Server) only(w http.ResponseWriter, r *http.Request, method string, handler func(w http.ResponseWriter, r *http.Request)) {
+	if r.Method != method {
+		reply(w, http.StatusMethodNotAllowed, &errorResponse{"method not allowed"})
+		return
+	}
+	handler(w, r)
+}
+
+func (s *Server) query(w http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+	text := "sort:" + params.Get("sort")
+	if params.Get("desc") == "true" {
+		text += " desc"
+	}
+	if where := params["where"]; len(where) > 0 {
+		text += " where " + strings.Join(where, " and ")
+	}
+	for _, name := range []string{"limit", "offset"} {
+		if value := params.Get(name); len(value) != 0 {
+			text += " " + name + " " + value
+		}
+	}
+	if params.Get("total") == "true" {
+		text += " total"
+	}
+
+	query, err := nabu.Parse(s.db, text, s.allowed...)
+	if err != nil {
+		reply(w, http.StatusBadRequest, &errorResponse{err.Error()})
+		return
+	}
+	result := query.Execute()
+	defer result.Close()
+	response := &queryResponse{
+		Ids:     result.Ids(),
+		Total:   result.Total(),
+		HasMore: result.HasMore(),
+	}
+	if params.Get("documents") != "false" {
+		response.Documents = result.Documents()
+	}
+	reply(w, http.StatusOK, response)
+}
+
+func (s *Server) indexes(w http.ResponseWriter, r *http.Request) {
+	reply(w, http.StatusOK, s.db.Indexes())
+}
+
+func (s *Server) get(w http.ResponseWriter, id string) {
+	var doc nabu.Document
+	if s.db.StringIds() {
+		doc = s.db.StringGet(id)
+	} else if intId, err := strconv.ParseUint(id, 10, 64); err == nil {
+		doc = s.db.Get(uint(intId))
+	}
+	if doc == nil {
+		reply(w, http.StatusNotFound, &errorResponse{"not found"})
+		return
+	}
+	reply(w, http.StatusOK, doc)
+}
+
+func (s *Server) put(w http.ResponseWriter, r *http.Request, id string) {
+	body, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, MAX_BODY_SIZE))
+	if err != nil {
+		reply(w, http.StatusRequestEntityTooLarge, &errorResponse{err.Error()})
+		return
+	}
+	doc, err := s.decode(id, r.URL.Query().Get("type"), body)
+	if err != nil {
+		reply(w, http.StatusBadRequest, &errorResponse{err.Error()})
+		return
+	}
+	switch err := s.db.Update(doc); err {
+	case nil:
+		w.WriteHeader(http.StatusNoContent)
+	case nabu.ErrStaleVersion, nabu.ErrUniqueConflict:
+		reply(w, http.StatusConflict, &errorResponse{err.Error()})
+	case nabu.ErrMemoryLimit:
+		reply(w, http.StatusInsufficientStorage, &errorResponse{err.Error()})
+	default:
+		reply(w, http.StatusBadRequest, &errorResponse{err.Error()})
+	}
+}
+
+// Factories tend to panic on invalid data, which is the client's fault
+func (s *Server) decode(id, t string, body []byte) (doc nabu.Document, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			doc, err = nil, &decodeError{r}
+		}
+	}()
+	doc, err = s.db.Decode(id, t, body, nabu.JSONCodec)
+	if err == nil && doc == nil {
+		err = &decodeError{"the factory returned no document"}
+	}
+	return doc, err
+}
+
+func (s *Server) remove(w http.ResponseWriter, id string) {
+	if s.db.StringIds() {
+		s.db.RemoveByStringId(id)
+	} else if intId, err := strconv.ParseUint(id, 10, 64); err == nil {
+		s.db.RemoveById(uint(intId))
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+type decodeError struct {
+	cause interface{}
+}
+
+func (e *decodeError) Error() string {
+	if err, ok := e.cause.(error); ok {
+		return "invalid document: " + err.Error()
+	}
+	if message, ok := e.cause.(string); ok {
+		return "invalid document: " + message
+	}
+	return "invalid document"
+}
+
+func reply(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
@@ -0,0 +1,157 @@
+package server
+
+import (
+	"encoding/json"
+	"github.com/karlseguin/gspec"
+	"github.com/karlseguin/nabu"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestServesQueries(t *testing.T) {
+	spec := gspec.New(t)
+	db := testDB()
+	defer db.Close()
+	s := New(db, "age", "city")
+	res := request(s, "GET", "/query?sort=age&desc=true&where=age>20&limit=2&total=true", "")
+	spec.Expect(res.Code).ToEqual(200)
+	body := make(map[string]interface{})
+	json.Unmarshal(res.Body.Bytes(), &body)
+	spec.Expect(body["ids"]).ToEqual([]interface{}{4.0, 3.0})
+	spec.Expect(body["total"]).ToEqual(3.0)
+	spec.Expect(body["hasMore"]).ToEqual(true)
+	spec.Expect(len(body["documents"].([]interface{}))).ToEqual(2)
+}
+
+func TestRejectsInvalidQueries(t *testing.T) {
+	spec := gspec.New(t)
+	db := testDB()
+	defer db.Close()
+	s := New(db, "age", "city")
+	spec.Expect(request(s, "GET", "/query?sort=name", "").Code).ToEqual(400)
+	spec.Expect(request(s, "GET", "/query?sort=age&limit=101", "").Code).ToEqual(400)
+	spec.Expect(request(s, "POST", "/query?sort=age", "").Code).ToEqual(405)
+}
+
+func TestGetsPutsAndDeletesDocuments(t *testing.T) {
+	spec := gspec.New(t)
+	db := testDB()
+	defer db.Close()
+	s := New(db)
+	spec.Expect(request(s, "GET", "/documents/9", "").Code).ToEqual(404)
+	spec.Expect(request(s, "PUT", "/documents/9?type=person", `{"id":9,"age":50,"city":"dune"}`).Code).ToEqual(204)
+	res := request(s, "GET", "/documents/9", "")
+	spec.Expect(res.Code).ToEqual(200)
+	spec.Expect(strings.TrimSpace(res.Body.String())).ToEqual(`{"id":9,"age":50,"city":"dune"}`)
+	spec.Expect(request(s, "DELETE", "/documents/9", "").Code).ToEqual(204)
+	spec.Expect(db.Get(9)).ToBeNil()
+}
+
+func TestRejectsInvalidDocuments(t *testing.T) {
+	spec := gspec.New(t)
+	db := testDB()
+	defer db.Close()
+	s := New(db)
+	spec.Expect(request(s, "PUT", "/documents/9", `{"id":`).Code).ToEqual(400)
+	spec.Expect(request(s, "PUT", "/documents/abc", `{}`).Code).ToEqual(400)
+}
+
+func TestRejectsDocumentsWhoseIdDiffersFromThePath(t *testing.T) {
+	spec := gspec.New(t)
+	db := testDB()
+	defer db.Close()
+	s := New(db)
+	res := request(s, "PUT", "/documents/9", `{"id":1,"age":99,"city":"dune"}`)
+	spec.Expect(res.Code).ToEqual(400)
+	spec.Expect(db.Get(1).(*Person).Age).ToEqual(10)
+	spec.Expect(db.Get(9)).ToBeNil()
+}
+
+func TestUsesStringIdsWithAStringFactory(t *testing.T) {
+	spec := gspec.New(t)
+	db := nabu.New(nabu.Configure().QueryPoolSize(1).ResultsPoolSize(1, 1).NoPersistence().SkipLoad().StringFactory(petFactory))
+	defer db.Close()
+	s := New(db)
+	spec.Expect(request(s, "PUT", "/documents/alpha", `{"id":"alpha"}`).Code).ToEqual(204)
+	spec.Expect(request(s, "PUT", "/documents/beta", `{"id":"beta"}`).Code).ToEqual(204)
+	spec.Expect(request(s, "GET", "/documents/1", "").Code).ToEqual(404)
+	spec.Expect(request(s, "GET", "/documents/alpha", "").Code).ToEqual(200)
+	spec.Expect(request(s, "DELETE", "/documents/2", "").Code).ToEqual(204)
+	spec.Expect(db.StringGet("beta")).ToNotBeNil()
+	spec.Expect(request(s, "DELETE", "/documents/beta", "").Code).ToEqual(204)
+	spec.Expect(db.StringGet("beta")).ToBeNil()
+}
+
+func TestDoesNotAllocateIdsForRejectedDocuments(t *testing.T) {
+	spec := gspec.New(t)
+	db := nabu.New(nabu.Configure().QueryPoolSize(1).ResultsPoolSize(1, 1).NoPersistence().SkipLoad().StringFactory(petFactory))
+	defer db.Close()
+	s := New(db)
+	spec.Expect(request(s, "PUT", "/documents/alpha", `{"id":"beta"}`).Code).ToEqual(400)
+	spec.Expect(request(s, "PUT", "/documents/alpha", `{"id":`).Code).ToEqual(400)
+	spec.Expect(db.MemoryStats().IdMap).ToEqual(0)
+	spec.Expect(request(s, "PUT", "/documents/alpha", `{"id":"alpha"}`).Code).ToEqual(204)
+	spec.Expect(db.MemoryStats().IdMap > 0).ToEqual(true)
+}
+
+func TestListsIndexes(t *testing.T) {
+	spec := gspec.New(t)
+	db := testDB()
+	defer db.Close()
+	res := request(New(db), "GET", "/indexes", "")
+	spec.Expect(res.Code).ToEqual(200)
+	indexes := make([]map[string]interface{}, 0)
+	json.Unmarshal(res.Body.Bytes(), &indexes)
+	spec.Expect(len(indexes)).ToEqual(2)
+}
+
+type Person struct {
+	Id   uint   `json:"id"`
+	Age  int    `json:"age"`
+	City string `json:"city"`
+}
+
+func (p *Person) ReadMeta(m *nabu.Meta) {
+	m.IntId(p.Id).Type("person").SortedInt("age", p.Age).Set("city", p.City, false)
+}
+
+func personFactory(id uint, t string, data []byte) nabu.Document {
+	person := new(Person)
+	if err := json.Unmarshal(data, person); err != nil {
+		panic(err)
+	}
+	return person
+}
+
+type Pet struct {
+	Id string `json:"id"`
+}
+
+func (p *Pet) ReadMeta(m *nabu.Meta) {
+	m.StringId(p.Id)
+}
+
+func petFactory(stringId string, id uint, t string, data []byte) nabu.Document {
+	pet := new(Pet)
+	if err := json.Unmarshal(data, pet); err != nil {
+		panic(err)
+	}
+	return pet
+}
+
+func testDB() *nabu.Database {
+	db := nabu.New(nabu.Configure().QueryPoolSize(1).ResultsPoolSize(1, 1).NoPersistence().SkipLoad().IntFactory(personFactory))
+	for i, age := range []int{10, 25, 30, 45} {
+		db.Update(&Person{uint(i + 1), age, "dune"})
+	}
+	return db
+}
+
+func request(s *Server, method, url, body string) *httptest.ResponseRecorder {
+	req, _ := http.NewRequest(method, url, strings.NewReader(body))
+	res := httptest.NewRecorder()
+	s.ServeHTTP(res, req)
+	return res
+}
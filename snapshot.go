@@ -0,0 +1,123 @@
+package nabu
+
+import (
+	"github.com/karlseguin/nabu/indexes"
+	"github.com/karlseguin/nabu/key"
+	"sort"
+	"sync/atomic"
+)
+
+// A point-in-time view of the database. Changes made after the snapshot
+// was taken aren't visible through it. Indexes and buckets are shared
+// with the database until they are next modified, at which point the
+// database modifies a copy. Close must be called once you are done
+// with it
+type Snapshot struct {
+	db      *Database
+	closed  int32
+	indexes map[string]indexes.Index
+	buckets map[int]map[key.Type]Document
+}
+
+// Takes a snapshot of the database
+func (d *Database) Snapshot() *Snapshot {
+	d.txnLock.Lock()
+	defer d.txnLock.Unlock()
+
+	s := &Snapshot{
+		db:      d,
+		buckets: make(map[int]map[key.Type]Document, len(d.Buckets)),
+	}
+	d.indexLock.RLock()
+	s.indexes = make(map[string]indexes.Index, len(d.indexes))
+	for name, index := range d.indexes {
+		s.indexes[name] = index
+	}
+	d.indexLock.RUnlock()
+
+	for i, bucket := range d.Buckets {
+		bucket.RLock()
+		s.buckets[i] = bucket.Lookup
+		bucket.RUnlock()
+	}
+	d.generation++
+	atomic.AddInt32(&d.snapshots, 1)
+	return s
+}
+
+// Generate a Query object against the specified sort index
+func (s *Snapshot) Query(indexName string) Query {
+	if schema := s.db.schema; schema != nil && schema.declares(indexName) == false {
+		return &EmptyQuery{err: &SchemaError{Index: indexName}}
+	}
+	index, exists := s.indexes[indexName].(indexes.Ranked)
+	if exists == false {
+		return emptyQuery
+	}
+	q := s.db.query()
+	q.sort = index
+	q.snapshot = s
+	return q
+}
+
+// Retrieves a document by id
+func (s *Snapshot) Get(id uint) Document {
+	typed := key.Type(id)
+	if now := s.db.expiry.now(); now != 0 && s.db.expiry.expired(typed, now) {
+		return nil
+	}
+	return s.db.hydrate(typed, s.buckets[typed.Bucket(s.db.bucketCount)][typed])
+}
+
+// Whether the index contains the unexpired document
+func (s *Snapshot) Contains(indexName string, id uint) bool {
+	if now := s.db.expiry.now(); now != 0 && s.db.expiry.expired(key.Type(id), now) {
+		return false
+	}
+	index, exists := s.indexes[indexName]
+	if exists == false {
+		return false
+	}
+	index.RLock()
+	defer index.RUnlock()
+	return index.Contains(key.Type(id))
+}
+
+// The ids of every document in the snapshot, in ascending order
+func (s *Snapshot) ids() []key.Type {
+	ids := make([]int, 0)
+	for _, bucket := range s.buckets {
+		for id := range bucket {
+			ids = append(ids, int(id))
+		}
+	}
+	sort.Ints(ids)
+	keys := make([]key.Type, len(ids))
+	for i, id := range ids {
+		keys[i] = key.Type(id)
+	}
+	return keys
+}
+
+// Releases the snapshot
+func (s *Snapshot) Close() {
+	if atomic.CompareAndSwapInt32(&s.closed, 0, 1) {
+		atomic.AddInt32(&s.db.snapshots, -1)
+	}
+}
+
+// A result whose documents are loaded from a snapshot
+type SnapshotResult struct {
+	Result
+	snapshot *Snapshot
+}
+
+// The actual documents, as they were when the snapshot was taken
+func (r *SnapshotResult) Documents() []Document {
+	ids := r.Ids()
+	documents := make([]Document, len(ids))
+	for i, id := range ids {
+		documents[i] = r.snapshot.Get(id)
+	}
+	return documents
+}
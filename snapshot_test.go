@@ -0,0 +1,100 @@
+package nabu
+
+import (
+	"github.com/karlseguin/gspec"
+	"testing"
+	"time"
+)
+
+func TestSnapshotIgnoresLaterChanges(t *testing.T) {
+	spec := gspec.New(t)
+	db := New(SmallConfig())
+	defer db.Close()
+	db.Update(NewDoc(1, map[string]int{"created": 1}))
+	db.Update(NewDoc(2, map[string]int{"created": 2}))
+	snapshot := db.Snapshot()
+	defer snapshot.Close()
+
+	db.Update(NewDoc(3, map[string]int{"created": 3}))
+	db.Update(NewDoc(1, map[string]int{"created": 4}))
+	db.RemoveById(2)
+
+	spec.Expect(snapshot.Get(2).(*Doc).id).ToEqual(uint(2))
+	spec.Expect(snapshot.Get(3)).ToBeNil()
+	spec.Expect(snapshot.Contains("created", 2)).ToEqual(true)
+	spec.Expect(snapshot.Contains("created", 3)).ToEqual(false)
+	assertClosedResult(t, snapshot.Query("created").Execute(), 1, 2)
+
+	spec.Expect(db.Get(2)).ToBeNil()
+	assertClosedResult(t, db.Query("created").Execute(), 3, 1)
+}
+
+func TestSnapshotHidesExpiredDocuments(t *testing.T) {
+	spec := gspec.New(t)
+	db := SmallDB()
+	defer db.Close()
+	db.Update(NewExpiringDoc(94, time.Now().Add(-time.Second), map[string]int{"age": 2}))
+	db.Update(NewExpiringDoc(95, time.Now().Add(time.Hour), map[string]int{"age": 3}))
+	snapshot := db.Snapshot()
+	defer snapshot.Close()
+	spec.Expect(snapshot.Get(94)).ToBeNil()
+	spec.Expect(snapshot.Contains("age", 94)).ToEqual(false)
+	spec.Expect(snapshot.Get(95).(*ExpiringDoc).id).ToEqual(uint(95))
+	spec.Expect(snapshot.Contains("age", 95)).ToEqual(true)
+}
+
+func TestSnapshotQueriesAreCheckedAgainstTheSchema(t *testing.T) {
+	spec := gspec.New(t)
+	db := New(SmallConfig().Schema(Schema{"created": SortedIntKind}))
+	defer db.Close()
+	db.Update(NewDoc(1, map[string]int{"created": 1}))
+	snapshot := db.Snapshot()
+	defer snapshot.Close()
+
+	query := snapshot.Query("secret")
+	spec.Expect(query.Err().Error()).ToEqual(`nabu: index "secret" is not declared`)
+	assertClosedResult(t, query.Execute())
+
+	query = snapshot.Query("created").Set("tag", "a")
+	spec.Expect(query.Err().Error()).ToEqual(`nabu: index "tag" is not declared`)
+	query.Execute().Close()
+	assertClosedResult(t, snapshot.Query("created").Execute(), 1)
+}
+
+func TestSnapshotQueriesWithConditions(t *testing.T) {
+	db := New(SmallConfig())
+	defer db.Close()
+	for i := uint(1); i < 6; i++ {
+		db.Update(NewDoc(i, map[string]int{"created": int(i), "age": int(i)}))
+	}
+	snapshot := db.Snapshot()
+	defer snapshot.Close()
+	db.Update(NewDoc(6, map[string]int{"created": 6, "age": 6}))
+	db.Update(NewDoc(4, map[string]int{"created": 4, "age": 0}))
+	assertClosedResult(t, snapshot.Query("created").Where(GT("age", 2)).Execute(), 3, 4, 5)
+	assertClosedResult(t, db.Query("created").Where(GT("age", 2)).Execute(), 3, 5, 6)
+}
+
+func TestSnapshotResultLoadsDocumentsFromTheSnapshot(t *testing.T) {
+	spec := gspec.New(t)
+	db := New(SmallConfig())
+	defer db.Close()
+	db.Update(NewDoc(1, map[string]int{"created": 1}))
+	snapshot := db.Snapshot()
+	defer snapshot.Close()
+	db.RemoveById(1)
+	result := snapshot.Query("created").Execute()
+	defer result.Close()
+	spec.Expect(result.Documents()[0].(*Doc).id).ToEqual(uint(1))
+}
+
+func TestClosedSnapshotsStopCopying(t *testing.T) {
+	spec := gspec.New(t)
+	db := New(SmallConfig())
+	defer db.Close()
+	db.Update(NewDoc(1, map[string]int{"created": 1}))
+	db.Snapshot().Close()
+	index := db.indexes["created"]
+	db.Update(NewDoc(2, map[string]int{"created": 2}))
+	spec.Expect(db.indexes["created"] == index).ToEqual(true)
+}
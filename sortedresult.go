@@ -12,6 +12,8 @@ type SortedResult struct {
 	hasMore   bool
 	documents []Document
 	ids       []uint
+	scores    []int
+	projections
 }
 
 func newSortedResult(db *Database) *SortedResult {
@@ -19,6 +21,7 @@ func newSortedResult(db *Database) *SortedResult {
 		db:        db,
 		found:     0,
 		ids:       make([]uint, db.maxLimit),
+		scores:    make([]int, db.maxLimit),
 		documents: make([]Document, db.maxLimit),
 	}
 }
@@ -39,9 +42,15 @@ func (r *SortedResult) Ids() []uint {
 	return r.ids[0:r.found]
 }
 
+func (r *SortedResult) Scores() []int {
+	return r.scores[0:r.found]
+}
+
 func (r *SortedResult) Documents() []Document {
+	r.db.txnLock.RLock()
+	defer r.db.txnLock.RUnlock()
 	for i := 0; i < r.found; i++ {
-		r.documents[i] = r.db.Get(r.ids[i])
+		r.documents[i] = r.db.get(key.Type(r.ids[i]))
 	}
 	return r.documents[0:r.found]
 }
@@ -50,11 +59,13 @@ func (r *SortedResult) Close() {
 	r.found = 0
 	r.total = 0
 	r.hasMore = false
+	r.projections.reset()
 	r.db.sortedResults <- r
 }
 
-func (r *SortedResult) add(value key.Type) int {
+func (r *SortedResult) add(value key.Type, score int) int {
 	r.ids[r.found] = uint(value)
+	r.scores[r.found] = score
 	r.found++
 	return r.found
 }
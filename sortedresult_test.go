@@ -8,13 +8,14 @@ import (
 func TestSortedResultAddsValues(t *testing.T) {
 	spec := gspec.New(t)
 	result := newSortedResult(SmallDB())
-	spec.Expect(result.add(5)).ToEqual(1)
-	spec.Expect(result.add(6)).ToEqual(2)
-	spec.Expect(result.add(7)).ToEqual(3)
+	spec.Expect(result.add(5, 50)).ToEqual(1)
+	spec.Expect(result.add(6, 60)).ToEqual(2)
+	spec.Expect(result.add(7, 70)).ToEqual(3)
 	spec.Expect(result.Len()).ToEqual(3)
 	spec.Expect(result.Ids()[0]).ToEqual(uint(5))
 	spec.Expect(result.Ids()[1]).ToEqual(uint(6))
 	spec.Expect(result.Ids()[2]).ToEqual(uint(7))
+	spec.Expect(result.Scores()[2]).ToEqual(70)
 }
 
 func TestSortedResultIsReleasedBackToTheDatabase(t *testing.T) {
@@ -30,14 +31,14 @@ func TestSortedResultCanBeSafelyReused(t *testing.T) {
 	spec := gspec.New(t)
 	db := SmallDB()
 	result := <-db.sortedResults
-	spec.Expect(result.add(7)).ToEqual(1)
-	spec.Expect(result.add(8)).ToEqual(2)
-	spec.Expect(result.add(9)).ToEqual(3)
+	spec.Expect(result.add(7, 0)).ToEqual(1)
+	spec.Expect(result.add(8, 0)).ToEqual(2)
+	spec.Expect(result.add(9, 0)).ToEqual(3)
 	result.total = 44
 	result.Close()
 	spec.Expect(result.total).ToEqual(0)
 	spec.Expect(result.Len()).ToEqual(0)
-	spec.Expect(result.add(2)).ToEqual(1)
+	spec.Expect(result.add(2, 0)).ToEqual(1)
 	spec.Expect(result.Ids()[0]).ToEqual(uint(2))
 	spec.Expect(len(result.Ids())).ToEqual(1)
 	spec.Expect(result.Len()).ToEqual(1)
@@ -2,11 +2,27 @@ package storage
 
 import (
 	"database/sql"
+	"errors"
+	"github.com/karlseguin/nabu/key"
 	_ "github.com/mattn/go-sqlite3"
+	"os"
+	"strings"
+	"sync"
 )
 
 type SQLite struct {
 	*sql.DB
+	*batch
+	prefix    string
+	documents string
+	mappings  string
+	sorts     string
+}
+
+// The transaction writes are grouped into, shared with namespaces
+type batch struct {
+	lock sync.RWMutex
+	tx   *sql.Tx
 }
 
 func newSQLite(path string) *SQLite {
@@ -14,50 +30,202 @@ func newSQLite(path string) *SQLite {
 	if err != nil {
 		panic(err)
 	}
-	tables := make(map[string]struct{}, 2)
-	rows, err := db.Query("select tbl_name from sqlite_master")
-	defer rows.Close()
-	for rows.Next() {
-		var table string
-		rows.Scan(&table)
+	s := &SQLite{DB: db, batch: new(batch)}
+	s.createTables("")
+	return s
+}
+
+// Opens an existing data file, read only unless writable. Unlike New, the
+// file and its tables are never created
+func Open(path string, writable bool) (*SQLite, error) {
+	if _, err := os.Stat(path); err != nil {
+		return nil, err
+	}
+	mode := "ro"
+	if writable {
+		mode = "rw"
+	}
+	db, err := sql.Open("sqlite3", "file:"+path+"?mode="+mode)
+	if err != nil {
+		return nil, err
+	}
+	s := &SQLite{DB: db, batch: new(batch)}
+	s.name("")
+	found := 0
+	for _, table := range s.tables() {
+		if table == "documents" || table == "mappings" {
+			found++
+		}
+	}
+	if found != 2 {
+		db.Close()
+		return nil, errors.New("storage: " + path + " is not a nabu data file")
+	}
+	return s, nil
+}
+
+// Names the tables after the prefix
+func (db *SQLite) name(prefix string) {
+	db.prefix = prefix
+	db.documents = quote(prefix + "documents")
+	db.mappings = quote(prefix + "mappings")
+	db.sorts = quote(prefix + "sorts")
+}
+
+// Creates the tables, whose names are prefixed, which don't exist
+func (db *SQLite) createTables(prefix string) {
+	db.name(prefix)
+
+	tables := make(map[string]struct{}, 3)
+	for _, table := range db.tables() {
 		tables[table] = struct{}{}
 	}
 
-	if _, exists := tables["documents"]; exists == false {
-		db.Exec("create table documents (id blob, value blob)")
+	// created within the open transaction, if any, which would otherwise
+	// keep the tables from being created
+	if _, exists := tables[prefix+"documents"]; exists == false {
+		db.exec("create table " + db.documents + " (id blob, value blob)")
 	}
 
-	if _, exists := tables["mappings"]; exists == false {
-		db.Exec("create table mappings (id string, value blob)")
+	if _, exists := tables[prefix+"mappings"]; exists == false {
+		db.exec("create table " + db.mappings + " (id string, value blob)")
 	}
 
-	return &SQLite{db}
+	if _, exists := tables[prefix+"sorts"]; exists == false {
+		db.exec("create table " + db.sorts + " (id string, value blob)")
+	}
+}
+
+// A view of the storage whose tables are prefixed by the namespace
+func (db *SQLite) Namespace(name string) Storage {
+	namespace := &SQLite{DB: db.DB, batch: db.batch}
+	namespace.createTables(db.prefix + name + ":")
+	return namespace
+}
+
+// Drops the namespace's tables
+func (db *SQLite) DropNamespace(name string) {
+	prefix := db.prefix + name + ":"
+	for _, table := range []string{"documents", "mappings", "sorts"} {
+		db.exec("drop table if exists " + quote(prefix+table))
+	}
+}
+
+// The names of the namespaces with stored tables, ordered. Namespaces of
+// namespaces are listed by their own parent
+func (db *SQLite) Namespaces() []string {
+	names := make([]string, 0)
+	for _, table := range db.tables() {
+		if strings.HasPrefix(table, db.prefix) == false {
+			continue
+		}
+		// the namespace's own tables are "documents" and the like
+		name := strings.TrimSuffix(table[len(db.prefix):], ":documents")
+		if len(name) != len(table)-len(db.prefix) && strings.Contains(name, ":") == false {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// The names of every table, ordered
+func (db *SQLite) tables() []string {
+	tables := make([]string, 0)
+	rows, err := db.Query("select tbl_name from sqlite_master where type = 'table' order by tbl_name")
+	if err != nil {
+		return tables
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var table string
+		rows.Scan(&table)
+		tables = append(tables, table)
+	}
+	return tables
 }
 
 func (db *SQLite) PutDocument(id, value []byte) {
-	result, _ := db.Exec("update documents set value = ? where id = ?", id, value)
+	result, err := db.exec("update "+db.documents+" set value = ? where id = ?", value, id)
+	if err != nil {
+		return
+	}
 	if c, _ := result.RowsAffected(); c == 0 {
-		db.Exec("insert into documents (id, value) values (?, ?)", id, value)
+		db.exec("insert into "+db.documents+" (id, value) values (?, ?)", id, value)
 	}
 }
 
 func (db *SQLite) PutMapping(id string, value []byte) {
-	result, _ := db.Exec("update mappings set value = ? where id = ?", id, value)
+	result, err := db.exec("update "+db.mappings+" set value = ? where id = ?", value, id)
+	if err != nil {
+		return
+	}
+	if c, _ := result.RowsAffected(); c == 0 {
+		db.exec("insert into "+db.mappings+" (id, value) values (?, ?)", id, value)
+	}
+}
+
+func (db *SQLite) PutSort(name string, value []byte) {
+	result, err := db.exec("update "+db.sorts+" set value = ? where id = ?", value, name)
+	if err != nil {
+		return
+	}
 	if c, _ := result.RowsAffected(); c == 0 {
-		db.Exec("insert into mappings (id, value) values (?, ?)", id, value)
+		db.exec("insert into "+db.sorts+" (id, value) values (?, ?)", name, value)
 	}
 }
 
+func (db *SQLite) RemoveSort(name string) {
+	db.exec("delete from "+db.sorts+" where id = ?", name)
+}
+
+func (db *SQLite) RenameSort(from, to string) {
+	db.exec("update "+db.sorts+" set id = ? where id = ?", to, from)
+}
+
 func (db *SQLite) RemoveDocument(id []byte) {
-	db.Exec("delete from documents where id = ?", id)
+	db.exec("delete from "+db.documents+" where id = ?", id)
 }
 
 func (db *SQLite) RemoveMapping(id string) {
-	db.Exec("delete from mappings where id = ?", id)
+	db.exec("delete from "+db.mappings+" where id = ?", id)
+}
+
+// Starts a transaction which subsequent writes join. Does nothing
+// if a transaction is already open
+func (db *SQLite) Begin() {
+	db.lock.Lock()
+	defer db.lock.Unlock()
+	if db.tx == nil {
+		db.tx, _ = db.DB.Begin()
+	}
+}
+
+// Commits the transaction started by Begin
+func (db *SQLite) Commit() error {
+	db.lock.Lock()
+	defer db.lock.Unlock()
+	if db.tx == nil {
+		return nil
+	}
+	err := db.tx.Commit()
+	db.tx = nil
+	return err
+}
+
+func (db *SQLite) exec(query string, args ...interface{}) (sql.Result, error) {
+	db.lock.RLock()
+	defer db.lock.RUnlock()
+	if db.tx != nil {
+		return db.tx.Exec(query, args...)
+	}
+	return db.DB.Exec(query, args...)
 }
 
 func (db *SQLite) IterateDocuments(handler func(id, value []byte)) {
-	rows, _ := db.Query("select id, value from documents")
+	rows, err := db.Query("select id, value from " + db.documents)
+	if err != nil {
+		return
+	}
 	defer rows.Close()
 	for rows.Next() {
 		var id []byte
@@ -68,7 +236,10 @@ func (db *SQLite) IterateDocuments(handler func(id, value []byte)) {
 }
 
 func (db *SQLite) IterateMappings(handler func(id string, value []byte)) {
-	rows, _	 := db.Query("select id, value from mappings")
+	rows, err := db.Query("select id, value from " + db.mappings)
+	if err != nil {
+		return
+	}
 	defer rows.Close()
 	for rows.Next() {
 		var id string
@@ -78,6 +249,133 @@ func (db *SQLite) IterateMappings(handler func(id string, value []byte)) {
 	}
 }
 
+func (db *SQLite) IterateSorts(handler func(name string, value []byte)) {
+	rows, err := db.Query("select id, value from " + db.sorts)
+	if err != nil {
+		// files written before sorts were persisted have no sorts table
+		return
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var name string
+		var value []byte
+		rows.Scan(&name, &value)
+		handler(name, value)
+	}
+}
+
+// Rewrites the documents and mappings, keeping only the most recently
+// written row for each id and normalizing serialized ids, then reclaims
+// unused space. Returns the number of rows removed
+func (db *SQLite) Compact() (int, error) {
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return 0, err
+	}
+	removed, err := compactDocuments(tx, db.documents)
+	if err == nil {
+		var n int
+		n, err = compactMappings(tx, db.mappings)
+		removed += n
+	}
+	if err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	_, err = db.DB.Exec("vacuum")
+	return removed, err
+}
+
+func compactDocuments(tx *sql.Tx, table string) (int, error) {
+	rows, err := tx.Query("select id, value from " + table + " order by rowid")
+	if err != nil {
+		return 0, err
+	}
+	order := make([]uint, 0)
+	latest := make(map[uint][]byte)
+	count := 0
+	for rows.Next() {
+		var id, value []byte
+		if err := rows.Scan(&id, &value); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		count++
+		if len(id) == 0 {
+			continue
+		}
+		k := key.Deserialize(id)
+		if _, exists := latest[k]; exists == false {
+			order = append(order, k)
+		}
+		latest[k] = value
+	}
+	rows.Close()
+	if _, err := tx.Exec("delete from " + table); err != nil {
+		return 0, err
+	}
+	for _, k := range order {
+		buffer := key.Type(k).Serialize()
+		_, err := tx.Exec("insert into "+table+" (id, value) values (?, ?)", buffer.Bytes(), latest[k])
+		buffer.Close()
+		if err != nil {
+			return 0, err
+		}
+	}
+	return count - len(order), nil
+}
+
+func compactMappings(tx *sql.Tx, table string) (int, error) {
+	rows, err := tx.Query("select id, value from " + table + " order by rowid")
+	if err != nil {
+		return 0, err
+	}
+	order := make([]string, 0)
+	latest := make(map[string]uint)
+	count := 0
+	for rows.Next() {
+		var id string
+		var value []byte
+		if err := rows.Scan(&id, &value); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		count++
+		if len(value) == 0 {
+			continue
+		}
+		if _, exists := latest[id]; exists == false {
+			order = append(order, id)
+		}
+		latest[id] = key.Deserialize(value)
+	}
+	rows.Close()
+	if _, err := tx.Exec("delete from " + table); err != nil {
+		return 0, err
+	}
+	for _, id := range order {
+		buffer := key.Type(latest[id]).Serialize()
+		_, err := tx.Exec("insert into "+table+" (id, value) values (?, ?)", id, buffer.Bytes())
+		buffer.Close()
+		if err != nil {
+			return 0, err
+		}
+	}
+	return count - len(order), nil
+}
+
+// Closes the database. Namespaces share their parent's
+// connection, which only the parent closes
 func (db *SQLite) Close() error {
+	if len(db.prefix) != 0 {
+		return nil
+	}
 	return db.DB.Close()
 }
+
+func quote(table string) string {
+	return `"` + strings.Replace(table, `"`, `""`, -1) + `"`
+}
@@ -16,9 +16,25 @@ type Storage interface {
 	PutDocument(id, value []byte)
 	PutMapping(id string, value []byte)
 
+	// Inserts, updates, removes or renames a bulk loaded sort index
+	PutSort(name string, value []byte)
+	RemoveSort(name string)
+	RenameSort(from, to string)
+
+	// Groups the writes made until Commit into a single transaction
+	Begin()
+	Commit() error
+
+	// A view of the storage isolated to the namespace, its removal and
+	// the names of the stored namespaces
+	Namespace(name string) Storage
+	DropNamespace(name string)
+	Namespaces() []string
+
 	// Iterate through all rows
 	IterateDocuments(handler func(id, value []byte))
 	IterateMappings(handler func(id string, value []byte))
+	IterateSorts(handler func(name string, value []byte))
 }
 
 // Creates a new storage isntance
@@ -37,6 +53,15 @@ func (s *nullStorage) RemoveDocument(id []byte) {}
 func (s *nullStorage) RemoveMapping(id string) {}
 func (s *nullStorage) PutDocument(id, value []byte) {}
 func (s *nullStorage) PutMapping(id string, value []byte) {}
+func (s *nullStorage) PutSort(name string, value []byte) {}
+func (s *nullStorage) RemoveSort(name string) {}
+func (s *nullStorage) RenameSort(from, to string) {}
+func (s *nullStorage) Begin() {}
+func (s *nullStorage) Commit() error { return nil }
+func (s *nullStorage) Namespace(name string) Storage { return s }
+func (s *nullStorage) DropNamespace(name string) {}
+func (s *nullStorage) Namespaces() []string { return nil }
 
 func (s *nullStorage) IterateDocuments(handler func(id, value []byte)){}
 func (s *nullStorage) IterateMappings(handler func(id string, value []byte)){}
+func (s *nullStorage) IterateSorts(handler func(name string, value []byte)) {}
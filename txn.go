@@ -0,0 +1,164 @@
+package nabu
+
+import (
+	"github.com/karlseguin/nabu/indexes"
+	"github.com/karlseguin/nabu/key"
+)
+
+// A set of changes staged within Database.Txn. Nothing is visible
+// until the transaction's function returns without an error
+type Txn struct {
+	operations []*operation
+}
+
+type operation struct {
+	doc      Document
+	remove   bool
+	id       key.Type
+	stringId string
+	// the metas of the document and of the one it replaces, which name
+	// every index the operation can change
+	metas []*Meta
+	// what those indexes held for the document before the operation
+	indexed *Meta
+}
+
+// Stages an insert or update of the document
+func (tx *Txn) Update(doc Document) {
+	if doc == nil {
+		return
+	}
+	tx.operations = append(tx.operations, &operation{doc: doc})
+}
+
+// Stages the removal of the document
+func (tx *Txn) Remove(doc Document) {
+	if doc == nil {
+		return
+	}
+	tx.operations = append(tx.operations, &operation{doc: doc, remove: true})
+}
+
+// Runs fn and publishes all of the changes it staged at once. Queries,
+// Get, Contains and a result's Documents observe either none or all of
+// the transaction's changes. When fn returns an error, the staged
+// changes are discarded and the error is returned. When a staged change
+// fails (such as an update with a stale version), the changes already
+// applied are rolled back
+func (d *Database) Txn(fn func(tx *Txn) error) error {
+	tx := new(Txn)
+	if err := fn(tx); err != nil {
+		return err
+	}
+	if err := d.checkMemory(); err != nil {
+		return err
+	}
+	d.txnLock.Lock()
+	defer d.txnLock.Unlock()
+	replaced := make([]Document, len(tx.operations))
+	for i, op := range tx.operations {
+		d.capture(op)
+		if op.remove {
+			replaced[i] = d.remove(op.doc)
+			continue
+		}
+		old, err := d.update(op.doc, d.updateCheck(), 0)
+		if err != nil {
+			d.rollback(tx.operations[:i], replaced)
+			return err
+		}
+		replaced[i] = old
+	}
+	return nil
+}
+
+// Undoes the applied operations, most recent first. The replaced
+// documents are stored again, but the indexes are restored from what
+// they held before each operation: a document mutated in place and
+// staged again is its own replaced document, so its meta no longer
+// describes the indexes it was removed from
+func (d *Database) rollback(operations []*operation, replaced []Document) {
+	for i := len(operations) - 1; i >= 0; i-- {
+		op := operations[i]
+		if old := replaced[i]; old != nil {
+			if op.remove && len(op.stringId) != 0 {
+				// remove dropped the mapping, without which old would get a new id
+				d.idMap.set(op.stringId, op.id)
+			}
+			d.update(old, noVersionCheck, 0)
+		} else if op.remove == false {
+			d.remove(op.doc)
+		}
+		d.uniques.take(op.id, op.indexed.uniques)
+		d.reindex(op.id, op.indexed, d.indexedMeta(op.id, op.metas))
+	}
+}
+
+// Records the operation's document id and what the indexes it can
+// change hold for the document
+func (d *Database) capture(op *operation) {
+	meta := newMeta(d, false)
+	d.readMeta(op.doc, meta)
+	op.id, op.stringId = meta.getId()
+	op.metas = []*Meta{meta}
+	if old := d.lookup(op.id); old != nil {
+		oldMeta := newMeta(d, false)
+		d.readMeta(old, oldMeta)
+		op.metas = append(op.metas, oldMeta)
+	}
+	op.indexed = d.indexedMeta(op.id, op.metas)
+}
+
+// What the indexes named by the metas hold for the document
+func (d *Database) indexedMeta(id key.Type, metas []*Meta) *Meta {
+	indexed := newMeta(d, false)
+	for _, meta := range metas {
+		for name, _ := range meta.sortedInts {
+			d.readIndexed(indexed, name, id)
+		}
+		for name, _ := range meta.sortedStrings {
+			d.readIndexed(indexed, name, id)
+		}
+		for name, _ := range meta.setStrings {
+			d.readIndexed(indexed, name, id)
+		}
+		for name, _ := range meta.bigSetStrings {
+			d.readIndexed(indexed, name, id)
+		}
+		for name, _ := range meta.dimensions {
+			if value, exists := d.dimensions.get(name, id); exists {
+				indexed.dimensions[name] = value
+			}
+		}
+		for name, value := range meta.uniques {
+			if owner, exists := d.uniques.get(name, value); exists && owner == id {
+				indexed.uniques[name] = value
+			}
+		}
+	}
+	indexed.expires, _ = d.expiry.get(id)
+	return indexed
+}
+
+func (d *Database) readIndexed(indexed *Meta, name string, id key.Type) {
+	index, exists := d.getIndex(name)
+	if exists == false {
+		return
+	}
+	index.RLock()
+	defer index.RUnlock()
+	switch typed := index.(type) {
+	case *indexes.SortedInts:
+		if score, exists := typed.Score(id); exists {
+			indexed.sortedInts[name] = score
+		}
+	case *indexes.SortedStrings:
+		if score, exists := typed.StringScore(id); exists {
+			indexed.sortedStrings[name] = score
+		}
+	case *indexes.SetString:
+		if typed.Contains(id) {
+			indexed.setStrings[name] = struct{}{}
+		}
+	}
+}
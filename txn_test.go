@@ -0,0 +1,110 @@
+package nabu
+
+import (
+	"errors"
+	"github.com/karlseguin/gspec"
+	"github.com/karlseguin/nabu/indexes"
+	"testing"
+)
+
+func TestTxnAppliesAllChanges(t *testing.T) {
+	spec := gspec.New(t)
+	db := SmallDB()
+	defer db.Close()
+	old := NewDoc(3, map[string]int{"age": 3})
+	db.Update(old)
+	err := db.Txn(func(tx *Txn) error {
+		tx.Update(NewDoc(1, map[string]int{"age": 1}))
+		tx.Update(NewDoc(2, map[string]int{"age": 2}))
+		tx.Remove(old)
+		return nil
+	})
+	spec.Expect(err).ToBeNil()
+	spec.Expect(db.Get(1).(*Doc).id).ToEqual(uint(1))
+	spec.Expect(db.Get(2).(*Doc).id).ToEqual(uint(2))
+	spec.Expect(db.Get(3)).ToBeNil()
+	spec.Expect(db.indexes["age"].Contains(3)).ToEqual(false)
+}
+
+func TestTxnDiscardsChangesOnError(t *testing.T) {
+	spec := gspec.New(t)
+	db := SmallDB()
+	defer db.Close()
+	old := NewDoc(3, map[string]int{"age": 3})
+	db.Update(old)
+	err := db.Txn(func(tx *Txn) error {
+		tx.Update(NewDoc(1, map[string]int{"age": 1}))
+		tx.Remove(old)
+		return errors.New("fail")
+	})
+	spec.Expect(err.Error()).ToEqual("fail")
+	spec.Expect(db.Get(1)).ToBeNil()
+	spec.Expect(db.Get(3).(*Doc).id).ToEqual(uint(3))
+	spec.Expect(db.indexes["age"].Contains(1)).ToEqual(false)
+}
+
+func TestTxnRollsBackOnStaleVersion(t *testing.T) {
+	spec := gspec.New(t)
+	db := New(SmallConfig().RejectStaleVersions())
+	defer db.Close()
+	db.Update(NewVersionedDoc(1, 1, 10))
+	db.Update(NewVersionedDoc(2, 5, 20))
+	db.Update(NewVersionedDoc(4, 1, 40))
+	err := db.Txn(func(tx *Txn) error {
+		tx.Update(NewVersionedDoc(1, 2, 11))
+		tx.Update(NewVersionedDoc(3, 1, 30))
+		tx.Remove(db.Get(4))
+		tx.Update(NewVersionedDoc(2, 4, 21))
+		return nil
+	})
+	spec.Expect(err).ToEqual(ErrStaleVersion)
+	spec.Expect(db.Get(1).(*VersionedDoc).version).ToEqual(uint64(1))
+	spec.Expect(db.Get(2).(*VersionedDoc).version).ToEqual(uint64(5))
+	spec.Expect(db.Get(3)).ToBeNil()
+	spec.Expect(db.Get(4).(*VersionedDoc).version).ToEqual(uint64(1))
+	spec.Expect(db.Contains("age", 3)).ToEqual(false)
+	spec.Expect(db.Contains("age", 4)).ToEqual(true)
+}
+
+func TestTxnRollbackKeepsTheIdOfARemovedStringDocument(t *testing.T) {
+	spec := gspec.New(t)
+	db := New(SmallConfig().RejectStaleVersions())
+	defer db.Close()
+	leto := NewStringDoc("leto", map[string]int{"age": 5})
+	db.Update(leto)
+	id := db.idMap.get("leto", false)
+	db.Update(NewVersionedDoc(2, 5, 20))
+	err := db.Txn(func(tx *Txn) error {
+		tx.Remove(leto)
+		tx.Update(NewVersionedDoc(2, 4, 21))
+		return nil
+	})
+	spec.Expect(err).ToEqual(ErrStaleVersion)
+	spec.Expect(db.idMap.get("leto", false)).ToEqual(id)
+	spec.Expect(db.StringGet("leto")).ToEqual(leto)
+	spec.Expect(db.indexes["age"].Contains(id)).ToEqual(true)
+}
+
+func TestTxnRollbackRestoresTheIndexesOfADocumentMutatedInPlace(t *testing.T) {
+	spec := gspec.New(t)
+	db := New(SmallConfig().RejectStaleVersions())
+	defer db.Close()
+	versioned := NewVersionedDoc(1, 1, 10)
+	tagged := NewSetDoc(3, "a")
+	db.Update(versioned)
+	db.Update(tagged)
+	db.Update(NewVersionedDoc(2, 5, 20))
+	err := db.Txn(func(tx *Txn) error {
+		versioned.version, versioned.indexes["age"] = 2, 50
+		tagged.tags = []string{"b"}
+		tx.Update(versioned)
+		tx.Update(tagged)
+		tx.Update(NewVersionedDoc(2, 4, 21))
+		return nil
+	})
+	spec.Expect(err).ToEqual(ErrStaleVersion)
+	score, _ := db.indexes["age"].(indexes.Ranked).Score(1)
+	spec.Expect(score).ToEqual(10)
+	spec.Expect(db.Contains("tag=a", 3)).ToEqual(true)
+	spec.Expect(db.Contains("tag=b", 3)).ToEqual(false)
+}
@@ -0,0 +1,116 @@
+package nabu
+
+import (
+	"errors"
+	"github.com/karlseguin/nabu/indexes"
+	"github.com/karlseguin/nabu/key"
+	"log"
+	"sync"
+)
+
+// Returned when an update gives a document a unique value (see
+// Meta.Unique) which belongs to another document
+var ErrUniqueConflict = errors.New("unique value belongs to another document")
+
+// Maps the values given to Meta.Unique to the id of their document
+type Uniques struct {
+	sync.RWMutex
+	memory int
+	lookup map[string]map[string]key.Type
+}
+
+func newUniques() *Uniques {
+	return &Uniques{
+		lookup: make(map[string]map[string]key.Type),
+	}
+}
+
+// Assigns each value to the document. Fails, assigning none of the
+// values, if any belongs to another document
+func (u *Uniques) claim(id key.Type, values map[string]string) bool {
+	if len(values) == 0 {
+		return true
+	}
+	u.Lock()
+	defer u.Unlock()
+	for name, value := range values {
+		if owner, exists := u.lookup[name][value]; exists && owner != id {
+			return false
+		}
+	}
+	u.assign(id, values)
+	return true
+}
+
+// Assigns each value to the document, including those which belong to
+// another document, which are logged. Used while restoring, where
+// documents are loaded in the order they were written, and to give a
+// rolled back document its values again
+func (u *Uniques) take(id key.Type, values map[string]string) {
+	u.Lock()
+	defer u.Unlock()
+	for name, value := range values {
+		if owner, exists := u.lookup[name][value]; exists && owner != id {
+			log.Printf("nabu: unique %s=%q of document %d now belongs to document %d", name, value, owner, id)
+		}
+	}
+	u.assign(id, values)
+}
+
+func (u *Uniques) assign(id key.Type, values map[string]string) {
+	for name, value := range values {
+		ids, exists := u.lookup[name]
+		if exists == false {
+			ids = make(map[string]key.Type)
+			u.lookup[name] = ids
+		}
+		if _, exists := ids[value]; exists == false {
+			u.memory += uniqueMemory(value)
+		}
+		ids[value] = id
+	}
+}
+
+// Releases the value, if it belongs to the document
+func (u *Uniques) release(name, value string, id key.Type) {
+	u.Lock()
+	defer u.Unlock()
+	ids := u.lookup[name]
+	if owner, exists := ids[value]; exists == false || owner != id {
+		return
+	}
+	u.memory -= uniqueMemory(value)
+	delete(ids, value)
+	if len(ids) == 0 {
+		delete(u.lookup, name)
+	}
+}
+
+func (u *Uniques) get(name, value string) (key.Type, bool) {
+	u.RLock()
+	defer u.RUnlock()
+	id, exists := u.lookup[name][value]
+	return id, exists
+}
+
+// Approximate number of bytes used
+func (u *Uniques) Memory() int {
+	u.RLock()
+	defer u.RUnlock()
+	return u.memory
+}
+
+func uniqueMemory(value string) int {
+	return len(value) + indexes.KEY_SIZE + indexes.STRING_HEADER_SIZE + indexes.MAP_ENTRY_OVERHEAD
+}
+
+// Gets the document whose Meta.Unique value for name is value
+func (d *Database) GetBy(name, value string) Document {
+	d.txnLock.RLock()
+	defer d.txnLock.RUnlock()
+	id, exists := d.uniques.get(name, value)
+	if exists == false {
+		return nil
+	}
+	return d.get(id)
+}
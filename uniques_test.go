@@ -0,0 +1,92 @@
+package nabu
+
+import (
+	"encoding/json"
+	"github.com/karlseguin/gspec"
+	"github.com/karlseguin/nabu/key"
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+)
+
+func TestGetsDocumentsByUniqueValues(t *testing.T) {
+	spec := gspec.New(t)
+	db := New(SmallConfig())
+	defer db.Close()
+	db.Update(&Account{1, "leto@dune.gov"})
+	db.Update(&Account{2, "paul@dune.gov"})
+	spec.Expect(db.GetBy("email", "leto@dune.gov").(*Account).Id).ToEqual(uint(1))
+	spec.Expect(db.GetBy("email", "ghanima@dune.gov")).ToBeNil()
+	spec.Expect(db.GetBy("slug", "leto@dune.gov")).ToBeNil()
+}
+
+func TestRejectsConflictingUniqueValues(t *testing.T) {
+	spec := gspec.New(t)
+	db := New(SmallConfig())
+	defer db.Close()
+	db.Update(&Account{1, "leto@dune.gov"})
+	spec.Expect(db.Update(&Account{2, "leto@dune.gov"})).ToEqual(ErrUniqueConflict)
+	spec.Expect(db.Get(2)).ToBeNil()
+	spec.Expect(db.Update(&Account{1, "leto@dune.gov"})).ToBeNil()
+
+	db.Update(&Account{1, "leto2@dune.gov"})
+	spec.Expect(db.GetBy("email", "leto@dune.gov")).ToBeNil()
+	spec.Expect(db.Update(&Account{2, "leto@dune.gov"})).ToBeNil()
+	spec.Expect(db.GetBy("email", "leto@dune.gov").(*Account).Id).ToEqual(uint(2))
+
+	db.RemoveById(2)
+	spec.Expect(db.GetBy("email", "leto@dune.gov")).ToBeNil()
+	spec.Expect(db.MemoryStats().Uniques).ToEqual(uniqueMemory("leto2@dune.gov"))
+}
+
+func TestRestoresUniqueValues(t *testing.T) {
+	spec := gspec.New(t)
+	dir, _ := ioutil.TempDir("", "nabu")
+	defer os.RemoveAll(dir)
+	config := Configure().QueryPoolSize(1).ResultsPoolSize(1, 1).DbPath(path.Join(dir, "data.db")).IntFactory(accountFactory)
+	db := New(config)
+	db.Update(&Account{1, "leto@dune.gov"})
+	db.Close()
+
+	db = New(config)
+	defer db.Close()
+	spec.Expect(db.GetBy("email", "leto@dune.gov").(*Account).Id).ToEqual(uint(1))
+	spec.Expect(db.Update(&Account{2, "leto@dune.gov"})).ToEqual(ErrUniqueConflict)
+}
+
+func TestRestoresConflictingUniqueValues(t *testing.T) {
+	spec := gspec.New(t)
+	dir, _ := ioutil.TempDir("", "nabu")
+	defer os.RemoveAll(dir)
+	config := Configure().QueryPoolSize(1).ResultsPoolSize(1, 1).DbPath(path.Join(dir, "data.db")).IntFactory(accountFactory)
+	db := New(config)
+	db.Update(&Account{1, "leto@dune.gov"})
+	value, _ := serializeValue(db.codec, "", &Account{2, "leto@dune.gov"})
+	id := key.Type(2).Serialize()
+	db.storage.PutDocument(id.Bytes(), value)
+	id.Close()
+	db.Close()
+
+	db = New(config)
+	defer db.Close()
+	spec.Expect(db.Get(1)).ToNotBeNil()
+	spec.Expect(db.GetBy("email", "leto@dune.gov").(*Account).Id).ToEqual(uint(2))
+}
+
+type Account struct {
+	Id    uint
+	Email string
+}
+
+func (a *Account) ReadMeta(m *Meta) {
+	m.IntId(a.Id).Unique("email", a.Email)
+}
+
+func accountFactory(id uint, t string, data []byte) Document {
+	account := new(Account)
+	if err := json.Unmarshal(data, account); err != nil {
+		panic(err)
+	}
+	return account
+}
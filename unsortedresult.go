@@ -13,8 +13,10 @@ type UnsortedResult struct {
 	db        *Database
 	documents []Document
 	ids       []uint
+	scores    []int
 	original  []uint
 	score     map[uint]int
+	projections
 }
 
 func newUnsortedResult(db *Database) *UnsortedResult {
@@ -29,6 +31,7 @@ func newUnsortedResult(db *Database) *UnsortedResult {
 		min = db.maxLimit
 	}
 	r.documents = make([]Document, min)
+	r.scores = make([]int, min)
 	return r
 }
 
@@ -48,9 +51,15 @@ func (r *UnsortedResult) Ids() []uint {
 	return r.ids[0:r.found]
 }
 
+func (r *UnsortedResult) Scores() []int {
+	return r.scores[0:r.found]
+}
+
 func (r *UnsortedResult) Documents() []Document {
+	r.db.txnLock.RLock()
+	defer r.db.txnLock.RUnlock()
 	for i := 0; i < r.found; i++ {
-		r.documents[i] = r.db.Get(r.ids[i])
+		r.documents[i] = r.db.get(key.Type(r.ids[i]))
 	}
 	return r.documents[0:r.found]
 }
@@ -99,6 +108,10 @@ func (r *UnsortedResult) finalize(q *NormalQuery) *UnsortedResult {
 		}
 	}
 
+	for i := 0; i < r.found; i++ {
+		r.scores[i] = r.score[r.ids[i]]
+	}
+
 	r.hasMore = r.found != 0 && r.total > (q.offset+r.found)
 	if q.includeTotal == false {
 		r.total = -1
@@ -108,10 +121,101 @@ func (r *UnsortedResult) finalize(q *NormalQuery) *UnsortedResult {
 	return r
 }
 
+// Adds the document to a heap of the size best scored documents, whose
+// root is the worst of them. Documents which rank no better than the
+// root of a full heap are only counted
+func (r *UnsortedResult) push(value key.Type, score int, size int, desc bool) {
+	r.total++
+	v := uint(value)
+	if r.found < size {
+		r.original[r.found] = v
+		r.score[v] = score
+		r.found++
+		r.siftUp(r.found-1, desc)
+		return
+	}
+	if size == 0 || r.before(v, score, r.original[0], desc) == false {
+		return
+	}
+	delete(r.score, r.original[0])
+	r.original[0] = v
+	r.score[v] = score
+	r.siftDown(0, r.found, desc)
+}
+
+// Orders the heap built by push, best first, and pages through it
+func (r *UnsortedResult) rank(q *NormalQuery) *UnsortedResult {
+	for end := r.found - 1; end > 0; end-- {
+		r.original[0], r.original[end] = r.original[end], r.original[0]
+		r.siftDown(0, end, q.desc)
+	}
+	from := q.offset
+	if from > r.found {
+		from = r.found
+	}
+	to := from + q.limit
+	if to > r.found {
+		to = r.found
+	}
+	r.ids = r.original[from:to]
+	r.found = to - from
+	for i := 0; i < r.found; i++ {
+		r.scores[i] = r.score[r.ids[i]]
+	}
+
+	r.hasMore = r.found != 0 && r.total > (q.offset+r.found)
+	if q.includeTotal == false {
+		r.total = -1
+	} else if q.upto < r.total {
+		r.total = q.upto
+	}
+	return r
+}
+
+// Whether the id, with the score, ranks ahead of the other id. Ties go
+// to the lower id
+func (r *UnsortedResult) before(id uint, score int, other uint, desc bool) bool {
+	otherScore := r.score[other]
+	if score == otherScore {
+		return id < other
+	}
+	return (score < otherScore) != desc
+}
+
+func (r *UnsortedResult) siftUp(i int, desc bool) {
+	heap := r.original
+	for i > 0 {
+		parent := (i - 1) / 2
+		if r.before(heap[parent], r.score[heap[parent]], heap[i], desc) == false {
+			return
+		}
+		heap[i], heap[parent] = heap[parent], heap[i]
+		i = parent
+	}
+}
+
+func (r *UnsortedResult) siftDown(i int, end int, desc bool) {
+	heap := r.original
+	for {
+		worst := i
+		for _, child := range [2]int{2*i + 1, 2*i + 2} {
+			if child < end && r.before(heap[worst], r.score[heap[worst]], heap[child], desc) {
+				worst = child
+			}
+		}
+		if worst == i {
+			return
+		}
+		heap[i], heap[worst] = heap[worst], heap[i]
+		i = worst
+	}
+}
+
 func (r *UnsortedResult) Close() {
 	r.found = 0
 	r.total = 0
 	r.hasMore = false
+	r.projections.reset()
 	r.db.unsortedResults <- r
 }
 
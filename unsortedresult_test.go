@@ -25,6 +25,8 @@ func TestUnsortedResultCanBeSorted(t *testing.T) {
 	spec.Expect(result.Ids()[0]).ToEqual(uint(2))
 	spec.Expect(result.Ids()[1]).ToEqual(uint(1))
 	spec.Expect(result.Ids()[2]).ToEqual(uint(3))
+	spec.Expect(result.Scores()[0]).ToEqual(2)
+	spec.Expect(result.Scores()[2]).ToEqual(9001)
 }
 
 func TestUnsortedResultCanBeSortedInDescendingOrder(t *testing.T) {